@@ -0,0 +1,566 @@
+package arith
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Vars gives the evaluator access to the shell variables
+type Vars interface {
+	Get(name string) (string, bool)
+	Set(name, value string) error
+}
+
+var errRecursion = errors.New("expression recursion level exceeded")
+
+// maxDepth bounds the recursion through variables whose values are
+// themselves expressions, e.g. a=b b=a
+const maxDepth = 1024
+
+// Eval evaluates a shell arithmetic expression. It supports the C operators
+// with their usual precedence, assignments, ++ and --, the ternary operator,
+// and integer constants in decimal, octal (010), hexadecimal (0xff) and
+// arbitrary bases (16#ff). Variables can be referenced by name and their
+// values are evaluated as expressions in turn. An empty expression is 0.
+func Eval(expr string, vars Vars) (int64, error) {
+	return eval(expr, vars, 0)
+}
+
+func eval(expr string, vars Vars, depth int) (int64, error) {
+	if depth > maxDepth {
+		return 0, errRecursion
+	}
+	if strings.TrimSpace(expr) == "" {
+		return 0, nil
+	}
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	p := &parser{tokens: tokens, expr: expr}
+	root, err := p.parseComma()
+	if err != nil {
+		return 0, err
+	}
+	if p.pos < len(p.tokens) {
+		return 0, p.syntaxError()
+	}
+
+	e := &evaluator{vars: vars, depth: depth}
+	value, err := e.eval(root)
+	if err != nil && !errors.Is(err, errRecursion) && depth == 0 {
+		return 0, fmt.Errorf("%s: %w", strings.TrimSpace(expr), err)
+	}
+	return value, err
+}
+
+// token is a number, identifier or operator of an expression
+type token struct {
+	kind  byte // 'n' for numbers, 'i' for identifiers, 'o' for operators
+	text  string
+	value int64
+}
+
+// operators lists every operator, longest first
+var operators = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"*=", "/=", "%=", "+=", "-=", "&=", "^=", "|=",
+	"+", "-", "*", "/", "%", "<", ">", "&", "^", "|", "!", "~",
+	"?", ":", "=", ",", "(", ")",
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(expr) && (isAlnum(expr[end]) || expr[end] == '#' || expr[end] == '@') {
+				end++
+			}
+			value, err := parseNumber(expr[i:end])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: 'n', text: expr[i:end], value: value})
+			i = end
+		case isAlnum(c):
+			end := i
+			for end < len(expr) && isAlnum(expr[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: 'i', text: expr[i:end]})
+			i = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("%s: syntax error: invalid arithmetic operator (error token is \"%s\")", expr, expr[i:])
+			}
+			tokens = append(tokens, token{kind: 'o', text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isAlnum(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// parseNumber parses an integer constant: decimal, octal with a leading 0,
+// hexadecimal with 0x, or base#digits for bases 2 to 64
+func parseNumber(text string) (int64, error) {
+	base := int64(10)
+	digits := text
+
+	if b, rest, ok := strings.Cut(text, "#"); ok {
+		n, err := strconv.ParseInt(b, 10, 64)
+		if err != nil || n < 2 || n > 64 {
+			return 0, fmt.Errorf("%s: invalid arithmetic base", text)
+		}
+		base, digits = n, rest
+	} else if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X") {
+		base, digits = 16, text[2:]
+	} else if len(text) > 1 && text[0] == '0' {
+		base, digits = 8, text[1:]
+	}
+
+	if digits == "" {
+		return 0, fmt.Errorf("%s: invalid integer constant", text)
+	}
+
+	var value int64
+	for i := 0; i < len(digits); i++ {
+		d := digitValue(digits[i], base)
+		if d < 0 || d >= base {
+			return 0, fmt.Errorf("%s: value too great for base (error token is \"%s\")", text, text)
+		}
+		value = value*base + d
+	}
+	return value, nil
+}
+
+// digitValue returns the value of a digit. Up to base 36 letters are case
+// insensitive; above it lowercase letters come first, then uppercase, @ and _.
+func digitValue(c byte, base int64) int64 {
+	switch {
+	case c >= '0' && c <= '9':
+		return int64(c - '0')
+	case c >= 'a' && c <= 'z':
+		return int64(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		if base <= 36 {
+			return int64(c-'A') + 10
+		}
+		return int64(c-'A') + 36
+	case c == '@':
+		return 62
+	case c == '_':
+		return 63
+	}
+	return -1
+}
+
+// node is a parsed expression
+type node struct {
+	op    string // operator, "num" for constants and "var" for variables
+	value int64
+	name  string
+	args  []*node
+}
+
+// parser builds expression trees by precedence climbing
+type parser struct {
+	tokens []token
+	pos    int
+	expr   string
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == 'o' {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *parser) syntaxError() error {
+	rest := ""
+	if p.pos < len(p.tokens) {
+		rest = p.tokens[p.pos].text
+		for _, t := range p.tokens[p.pos+1:] {
+			rest += " " + t.text
+		}
+		return fmt.Errorf("%s: syntax error in expression (error token is \"%s\")", strings.TrimSpace(p.expr), rest)
+	}
+	return fmt.Errorf("%s: syntax error: operand expected", strings.TrimSpace(p.expr))
+}
+
+// binaryLevels lists the left-associative binary operators from the lowest
+// to the highest precedence
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", ">", "<=", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) parseComma() (*node, error) {
+	left, err := p.parseAssign()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "," {
+		p.pos++
+		right, err := p.parseAssign()
+		if err != nil {
+			return nil, err
+		}
+		left = &node{op: ",", args: []*node{left, right}}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAssign() (*node, error) {
+	// An assignment starts with a variable name followed by = or op=
+	if p.pos+1 < len(p.tokens) && p.tokens[p.pos].kind == 'i' && p.tokens[p.pos+1].kind == 'o' {
+		op := p.tokens[p.pos+1].text
+		if op == "=" || (strings.HasSuffix(op, "=") && !isComparison(op)) {
+			name := p.tokens[p.pos].text
+			p.pos += 2
+			value, err := p.parseAssign()
+			if err != nil {
+				return nil, err
+			}
+			return &node{op: op, name: name, args: []*node{value}}, nil
+		}
+	}
+	return p.parseTernary()
+}
+
+func isComparison(op string) bool {
+	return op == "==" || op == "!=" || op == "<=" || op == ">="
+}
+
+func (p *parser) parseTernary() (*node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "?" {
+		return cond, nil
+	}
+	p.pos++
+	then, err := p.parseAssign()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ":" {
+		return nil, p.syntaxError()
+	}
+	p.pos++
+	otherwise, err := p.parseAssign()
+	if err != nil {
+		return nil, err
+	}
+	return &node{op: "?", args: []*node{cond, then, otherwise}}, nil
+}
+
+func (p *parser) parseBinary(level int) (*node, error) {
+	if level == len(binaryLevels) {
+		return p.parsePower()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !contains(binaryLevels[level], op) {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &node{op: op, args: []*node{left, right}}
+	}
+}
+
+// parsePower parses the right-associative ** operator
+func (p *parser) parsePower() (*node, error) {
+	base, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "**" {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.parsePower()
+	if err != nil {
+		return nil, err
+	}
+	return &node{op: "**", args: []*node{base, exponent}}, nil
+}
+
+func (p *parser) parseUnary() (*node, error) {
+	switch op := p.peek(); op {
+	case "++", "--":
+		p.pos++
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != 'i' {
+			return nil, p.syntaxError()
+		}
+		name := p.tokens[p.pos].text
+		p.pos++
+		return &node{op: "pre" + op, name: name}, nil
+	case "+", "-", "!", "~":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &node{op: "unary" + op, args: []*node{operand}}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (*node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, p.syntaxError()
+	}
+
+	tok := p.tokens[p.pos]
+	switch {
+	case tok.kind == 'n':
+		p.pos++
+		return &node{op: "num", value: tok.value}, nil
+	case tok.kind == 'i':
+		p.pos++
+		if op := p.peek(); op == "++" || op == "--" {
+			p.pos++
+			return &node{op: "post" + op, name: tok.text}, nil
+		}
+		return &node{op: "var", name: tok.text}, nil
+	case tok.text == "(":
+		p.pos++
+		inner, err := p.parseComma()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, p.syntaxError()
+		}
+		p.pos++
+		return inner, nil
+	}
+	return nil, p.syntaxError()
+}
+
+func contains(ops []string, op string) bool {
+	for _, candidate := range ops {
+		if candidate == op {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluator computes the value of an expression tree. Operands that are
+// not needed, like the right side of a false &&, are not evaluated so
+// their side effects do not happen.
+type evaluator struct {
+	vars  Vars
+	depth int
+}
+
+func (e *evaluator) eval(n *node) (int64, error) {
+	switch n.op {
+	case "num":
+		return n.value, nil
+	case "var":
+		return e.variable(n.name)
+	case "pre++", "pre--", "post++", "post--":
+		old, err := e.variable(n.name)
+		if err != nil {
+			return 0, err
+		}
+		updated := old + 1
+		if strings.HasSuffix(n.op, "--") {
+			updated = old - 1
+		}
+		if err := e.vars.Set(n.name, strconv.FormatInt(updated, 10)); err != nil {
+			return 0, err
+		}
+		if strings.HasPrefix(n.op, "pre") {
+			return updated, nil
+		}
+		return old, nil
+	case "&&", "||":
+		left, err := e.eval(n.args[0])
+		if err != nil {
+			return 0, err
+		}
+		if (n.op == "&&") == (left == 0) {
+			return boolValue(left != 0), nil
+		}
+		right, err := e.eval(n.args[1])
+		if err != nil {
+			return 0, err
+		}
+		return boolValue(right != 0), nil
+	case "?":
+		cond, err := e.eval(n.args[0])
+		if err != nil {
+			return 0, err
+		}
+		if cond != 0 {
+			return e.eval(n.args[1])
+		}
+		return e.eval(n.args[2])
+	}
+
+	if strings.HasSuffix(n.op, "=") && n.name != "" {
+		return e.assign(n)
+	}
+
+	values := make([]int64, len(n.args))
+	for i, arg := range n.args {
+		value, err := e.eval(arg)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = value
+	}
+
+	if strings.HasPrefix(n.op, "unary") {
+		return unary(strings.TrimPrefix(n.op, "unary"), values[0]), nil
+	}
+	return binary(n.op, values[0], values[1])
+}
+
+// assign evaluates = and the compound assignment operators like +=
+func (e *evaluator) assign(n *node) (int64, error) {
+	value, err := e.eval(n.args[0])
+	if err != nil {
+		return 0, err
+	}
+	if n.op != "=" {
+		old, err := e.variable(n.name)
+		if err != nil {
+			return 0, err
+		}
+		if value, err = binary(strings.TrimSuffix(n.op, "="), old, value); err != nil {
+			return 0, err
+		}
+	}
+	if err := e.vars.Set(n.name, strconv.FormatInt(value, 10)); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+// variable returns the value of a variable, evaluating its content as an
+// expression. Unset and empty variables are 0.
+func (e *evaluator) variable(name string) (int64, error) {
+	value, _ := e.vars.Get(name)
+	return eval(value, e.vars, e.depth+1)
+}
+
+func unary(op string, x int64) int64 {
+	switch op {
+	case "-":
+		return -x
+	case "!":
+		return boolValue(x == 0)
+	case "~":
+		return ^x
+	}
+	return x
+}
+
+func binary(op string, x, y int64) (int64, error) {
+	switch op {
+	case ",":
+		return y, nil
+	case "|":
+		return x | y, nil
+	case "^":
+		return x ^ y, nil
+	case "&":
+		return x & y, nil
+	case "==":
+		return boolValue(x == y), nil
+	case "!=":
+		return boolValue(x != y), nil
+	case "<":
+		return boolValue(x < y), nil
+	case ">":
+		return boolValue(x > y), nil
+	case "<=":
+		return boolValue(x <= y), nil
+	case ">=":
+		return boolValue(x >= y), nil
+	case "<<":
+		return x << uint64(y), nil
+	case ">>":
+		return x >> uint64(y), nil
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "%":
+		if y == 0 {
+			return 0, errors.New("division by 0")
+		}
+		if op == "/" {
+			return x / y, nil
+		}
+		return x % y, nil
+	case "**":
+		if y < 0 {
+			return 0, errors.New("exponent less than 0")
+		}
+		// Exponentiation by squaring
+		result := int64(1)
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				result *= x
+			}
+			x *= x
+		}
+		return result, nil
+	}
+	return 0, fmt.Errorf("%s: unknown operator", op)
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+package arith
+
+import (
+	"strings"
+	"testing"
+)
+
+// vars is a variable store for the tests
+type vars map[string]string
+
+func (v vars) Get(name string) (string, bool) {
+	value, ok := v[name]
+	return value, ok
+}
+
+func (v vars) Set(name, value string) error {
+	v[name] = value
+	return nil
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"7 / 2", 3},
+		{"-7 / 2", -3},
+		{"-7 % 3", -1},
+		{"-(-4)", 4},
+		{"1 << 4 | 1", 17},
+		{"5 & 3 ^ 1", 0},
+		{"~0", -1},
+		{"!0 + !5", 1},
+		{"5 >= 5", 1},
+		{"3 != 3", 0},
+		{"3 > 2 && 0 || 4 == 4", 1},
+		{"1 ? 2 : 3", 2},
+		{"0 ? 2 : 0 ? 4 : 5", 5},
+		{"1, 2, 3", 3},
+		{"0x1f + 010 + 2#101 + 36#z + 64#_", 142},
+		{"", 0},
+		{"n * 2", 10},
+		{"ref * 2", 10},
+		{"unset + 1", 1},
+		{"empty + 1", 1},
+	}
+
+	for _, tt := range tests {
+		v := vars{"n": "5", "ref": "n", "empty": ""}
+		got, err := Eval(tt.expr, v)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q = %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalAssignments(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value string // initial value of x
+		want  int64
+		x     string // value of x afterwards
+	}{
+		{"x = 4", "", 4, "4"},
+		{"x += 2", "5", 7, "7"},
+		{"x -= 5", "1", -4, "-4"},
+		{"x *= 3, x %= 4", "7", 1, "1"},
+		{"x /= 2", "5", 2, "2"},
+		{"x <<= 3", "1", 8, "8"},
+		{"x >>= 1", "8", 4, "4"},
+		{"x |= 1", "8", 9, "9"},
+		{"x ^= 3", "9", 10, "10"},
+		{"x &= 6", "10", 2, "2"},
+		{"x++", "3", 3, "4"},
+		{"++x", "3", 4, "4"},
+		{"x--", "3", 3, "2"},
+		{"--x", "3", 2, "2"},
+		{"x == 3 ? x = 10 : 0", "3", 10, "10"},
+		{"y = x = 6", "", 6, "6"},
+	}
+
+	for _, tt := range tests {
+		v := vars{"x": tt.value}
+		got, err := Eval(tt.expr, v)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want || v["x"] != tt.x {
+			t.Errorf("%q with x=%q = %d and x=%q, want %d and x=%q", tt.expr, tt.value, got, v["x"], tt.want, tt.x)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"1/0", "1/0: division by 0"},
+		{"5%0", "5%0: division by 0"},
+		{"x /= 0", "division by 0"},
+		{"2**-1", "exponent less than 0"},
+		{"1 +", "operand expected"},
+		{"x=", "operand expected"},
+		{"a b", `syntax error in expression (error token is "b")`},
+		{"08", "value too great for base"},
+		{"2#102", "value too great for base"},
+		{"65#1", "invalid arithmetic base"},
+		{"self", "expression recursion level exceeded"},
+		{"loop", "expression recursion level exceeded"},
+	}
+
+	for _, tt := range tests {
+		v := vars{"x": "1", "self": "self", "loop": "loop + 1"}
+		_, err := Eval(tt.expr, v)
+		if err == nil {
+			t.Errorf("%q: expected an error containing %q", tt.expr, tt.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: error %q does not contain %q", tt.expr, err, tt.err)
+		}
+	}
+}
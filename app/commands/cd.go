@@ -1,43 +1,41 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"io/fs"
 )
 
-func CdImpl(args *[]string) {
-	pwd, _ := os.Getwd()
-	os.Setenv("OLDPWD", os.Getenv("PWD"))
-	os.Setenv("PWD", pwd)
+// CdImpl changes the working directory and returns the exit status. On
+// success PWD and OLDPWD are updated so that $PWD and cd - stay accurate.
+func CdImpl(args *[]string, env Env, stderr io.Writer) int {
+	home, _ := env.Get("HOME")
 
-	if args == nil || len(*args) == 0 {
-		os.Chdir(os.Getenv("HOME"))
-		return
-	}
-
-	dir := (*args)[0]
-
-	if dir == "~" {
-		dir = os.Getenv("HOME")
+	dir := home
+	if args != nil && len(*args) > 0 {
+		dir = (*args)[0]
 	}
 
 	if dir == "-" {
-		dir = os.Getenv("OLDPWD")
+		dir, _ = env.Get("OLDPWD")
 		if dir == "" {
-			fmt.Fprintln(os.Stderr, "cd: OLDPWD not set")
-			return
+			fmt.Fprintln(stderr, "cd: OLDPWD not set")
+			return 1
 		}
-		err := os.Chdir(dir)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "cd: %s: No such file or directory\n", dir)
-			return
-		}
-		return
 	}
 
-	err := os.Chdir(dir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "cd: %s: No such file or directory\n", dir)
-		return
+	oldPwd := env.Getwd()
+	if err := env.Chdir(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(stderr, "cd: %s: No such file or directory\n", dir)
+		} else {
+			fmt.Fprintf(stderr, "cd: %s: Not a directory\n", dir)
+		}
+		return 1
 	}
+
+	env.Set("OLDPWD", oldPwd)
+	env.Set("PWD", env.Getwd())
+	return 0
 }
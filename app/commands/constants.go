@@ -1,11 +1,19 @@
 package commands
 
 const (
-	EXIT = "exit"
-	ECHO = "echo"
-	TYPE = "type"
-	PWD  = "pwd"
-	CD   = "cd"
+	EXIT     = "exit"
+	ECHO     = "echo"
+	TYPE     = "type"
+	PWD      = "pwd"
+	CD       = "cd"
+	SET      = "set"
+	LET      = "let"
+	SHOPT    = "shopt"
+	BREAK    = "break"
+	CONTINUE = "continue"
+	RETURN   = "return"
+	LOCAL    = "local"
+	READ     = "read"
 )
 
 var COMMANDS = []string{
@@ -14,4 +22,12 @@ var COMMANDS = []string{
 	TYPE,
 	PWD,
 	CD,
+	SET,
+	LET,
+	SHOPT,
+	BREAK,
+	CONTINUE,
+	RETURN,
+	LOCAL,
+	READ,
 }
@@ -1,13 +1,13 @@
 package commands
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"strings"
+)
 
-func EchoImpl(args []string) {
-	for i, arg := range args {
-		if i > 0 {
-			fmt.Print(" ") // Print space *before* next argument (not after)
-		}
-		fmt.Print(arg)
-	}
-	fmt.Println() // Add newline at the end (like Bash)
+// EchoImpl writes its arguments separated by spaces and a newline
+func EchoImpl(args []string, stdout io.Writer) error {
+	_, err := fmt.Fprintln(stdout, strings.Join(args, " "))
+	return err
 }
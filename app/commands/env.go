@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Env is the shell state that builtins and external commands run against.
+// It is implemented by the interpreter.
+type Env interface {
+	// Get returns the value of a shell variable or special parameter (like
+	// ? for the last exit status) and whether it is set
+	Get(name string) (string, bool)
+	// Set assigns a shell variable
+	Set(name, value string) error
+	// Environ returns the exported variables as NAME=value pairs
+	Environ() []string
+	// Getwd returns the shell's working directory
+	Getwd() string
+	// Chdir changes the shell's working directory. A relative dir is
+	// resolved against the current one.
+	Chdir(dir string) error
+	// IsFunction reports whether name is a shell function
+	IsFunction(name string) bool
+	// ProcessStarted is called with the PID of every external command
+	// right after it has been started
+	ProcessStarted(pid int)
+	// ProcessExited is called with the PID of every external command once
+	// it has ended
+	ProcessExited(pid int)
+}
+
+// LookPath searches the directories of the shell's $PATH for an executable
+// named file, the way exec.LookPath searches the PATH of the process.
+// Relative directories are resolved against the shell's working directory.
+func LookPath(file string, env Env) (string, error) {
+	pathVar, _ := env.Get("PATH")
+	for _, dir := range filepath.SplitList(pathVar) {
+		if dir == "" {
+			dir = "."
+		}
+		path := filepath.Join(dir, file)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(env.Getwd(), path)
+		}
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
@@ -2,19 +2,20 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strconv"
 )
 
-func ExitImpl(codeStr *string) {
-	fmt.Fprint(os.Stdout, "exit\n")
+// ExitImpl returns the status the shell exits with for the given exit code
+// argument, reduced to the 0-255 range
+func ExitImpl(codeStr *string, stderr io.Writer) int {
 	if codeStr == nil {
-		os.Exit(0)
+		return 0
 	}
 	codeInt, err := strconv.Atoi(*codeStr)
 	if err != nil {
-		fmt.Fprint(os.Stderr, "Invalid exit code:", *codeStr)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "exit: %s: numeric argument required\n", *codeStr)
+		return 2
 	}
-	os.Exit(codeInt)
+	return codeInt & 0xff
 }
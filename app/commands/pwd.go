@@ -2,11 +2,11 @@ package commands
 
 import (
 	"fmt"
-	"os"
+	"io"
 )
 
-func PwdImpl() {
-	dir, _ := os.Getwd()
-
-	fmt.Fprintln(os.Stdout, dir)
+// PwdImpl writes the working directory
+func PwdImpl(env Env, stdout io.Writer) error {
+	_, err := fmt.Fprintln(stdout, env.Getwd())
+	return err
 }
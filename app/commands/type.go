@@ -2,18 +2,25 @@ package commands
 
 import (
 	"fmt"
-	"os/exec"
+	"io"
 	"slices"
 )
 
-func TypeImpl(args []string) {
+// TypeImpl describes how each name would be interpreted as a command. It
+// returns 1 if any of the names could not be found.
+func TypeImpl(args []string, env Env, stdout io.Writer) int {
+	status := 0
 	for i, cmd := range args {
-		if slices.Contains(COMMANDS, cmd) {
-			fmt.Println(args[i] + " is a shell builtin")
-		} else if path, err := exec.LookPath(args[i]); err == nil {
-			fmt.Printf("%s is %s\n", args[i], path)
+		if env.IsFunction(cmd) {
+			fmt.Fprintln(stdout, args[i]+" is a function")
+		} else if slices.Contains(COMMANDS, cmd) {
+			fmt.Fprintln(stdout, args[i]+" is a shell builtin")
+		} else if path, err := LookPath(args[i], env); err == nil {
+			fmt.Fprintf(stdout, "%s is %s\n", args[i], path)
 		} else {
-			fmt.Println(args[i] + ": not found")
+			fmt.Fprintln(stdout, args[i]+": not found")
+			status = 1
 		}
 	}
+	return status
 }
@@ -0,0 +1,320 @@
+package glob
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Match reports whether name matches the shell pattern. The pattern supports
+// *, ?, bracket expressions like [a-z], [!0-9] and [[:alpha:]], and a
+// backslash that makes the following character match literally.
+func Match(pattern, name string) bool {
+	return match([]rune(pattern), []rune(name))
+}
+
+// HasMeta reports whether the pattern contains an unescaped *, ? or [
+func HasMeta(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// QuoteMeta escapes every special character of s so that the result, used
+// as a pattern, only matches s itself
+func QuoteMeta(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func match(pattern, name []rune) bool {
+	// Position to resume from after the last * when a later part fails
+	starPattern, starName := -1, 0
+
+	p, n := 0, 0
+	for n < len(name) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				// Remember the star and first try matching it against nothing
+				starPattern, starName = p, n
+				p++
+				continue
+			case '?':
+				p++
+				n++
+				continue
+			case '[':
+				if matched, width, ok := matchBracket(pattern[p:], name[n]); ok {
+					if matched {
+						p += width
+						n++
+						continue
+					}
+				} else if name[n] == '[' {
+					// An unterminated bracket is an ordinary character
+					p++
+					n++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == name[n] {
+					p += 2
+					n++
+					continue
+				}
+			default:
+				if pattern[p] == name[n] {
+					p++
+					n++
+					continue
+				}
+			}
+		}
+
+		// Mismatch: let the last star swallow one more character
+		if starPattern < 0 {
+			return false
+		}
+		starName++
+		p, n = starPattern+1, starName
+	}
+
+	// The name is consumed; only stars may remain in the pattern
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchBracket matches r against the bracket expression at the start of
+// pattern. It returns whether r matched, the width of the expression, and
+// false as the last value if the expression is not terminated.
+func matchBracket(pattern []rune, r rune) (bool, int, bool) {
+	i := 1
+	negate := false
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		negate = true
+		i++
+	}
+
+	matched := false
+	first := true
+	for i < len(pattern) {
+		c := pattern[i]
+		if c == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+		first = false
+
+		// Character classes such as [:alpha:]
+		if c == '[' && i+1 < len(pattern) && pattern[i+1] == ':' {
+			if end := indexClassEnd(pattern[i+2:]); end >= 0 {
+				if matchClass(string(pattern[i+2:i+2+end]), r) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		if c == '\\' && i+1 < len(pattern) {
+			i++
+			c = pattern[i]
+		}
+
+		// Ranges such as a-z
+		if i+2 < len(pattern) && pattern[i+1] == '-' && pattern[i+2] != ']' {
+			hi := pattern[i+2]
+			width := 3
+			if hi == '\\' && i+3 < len(pattern) {
+				hi = pattern[i+3]
+				width = 4
+			}
+			if c <= r && r <= hi {
+				matched = true
+			}
+			i += width
+			continue
+		}
+
+		if c == r {
+			matched = true
+		}
+		i++
+	}
+	return false, 0, false
+}
+
+// indexClassEnd returns the index of the :] closing a character class
+func indexClassEnd(pattern []rune) int {
+	for i := 0; i+1 < len(pattern); i++ {
+		if pattern[i] == ':' && pattern[i+1] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchClass reports whether r belongs to the named POSIX character class
+func matchClass(class string, r rune) bool {
+	switch class {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return r >= '0' && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}
+	return false
+}
+
+// Options controls how Expand matches file names
+type Options struct {
+	Dir     string // directory relative patterns are matched in
+	DotGlob bool   // let wildcards match a leading dot
+	NoCase  bool   // match letters regardless of case
+}
+
+// Expand returns the sorted paths matching the pattern, or nil if there are
+// none. Each /-separated component is matched against the entries of the
+// directories matched so far. A leading dot in a file name must be matched
+// explicitly unless opts.DotGlob is set.
+func Expand(pattern string, opts Options) []string {
+	components := strings.Split(pattern, "/")
+	paths := []string{""}
+	if strings.HasPrefix(pattern, "/") {
+		paths = []string{"/"}
+		components = components[1:]
+	}
+
+	for i, component := range components {
+		var next []string
+		for _, dir := range paths {
+			switch {
+			case component == "":
+				// A trailing or repeated slash only keeps directories
+				if i > 0 && isDir(opts.resolve(dir)) {
+					next = append(next, join(dir, ""))
+				}
+			case !HasMeta(component):
+				path := join(dir, unescape(component))
+				if _, err := os.Lstat(opts.resolve(path)); err == nil {
+					next = append(next, path)
+				}
+			default:
+				next = append(next, matchDir(dir, component, opts)...)
+			}
+		}
+		paths = next
+		if len(paths) == 0 {
+			return nil
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// matchDir returns the entries of dir matching a single path component
+func matchDir(dir, component string, opts Options) []string {
+	entries, err := os.ReadDir(opts.resolve(dir))
+	if err != nil {
+		return nil
+	}
+
+	explicitDot := strings.HasPrefix(component, ".") || strings.HasPrefix(component, `\.`)
+	if opts.NoCase {
+		component = strings.ToLower(component)
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") && !explicitDot && !opts.DotGlob {
+			continue
+		}
+		candidate := name
+		if opts.NoCase {
+			candidate = strings.ToLower(name)
+		}
+		if Match(component, candidate) {
+			matches = append(matches, join(dir, name))
+		}
+	}
+	return matches
+}
+
+// resolve returns the path to access the file at path, which Expand builds
+// relative to opts.Dir unless it is absolute
+func (opts Options) resolve(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	return join(opts.Dir, path)
+}
+
+// join appends a file name to a directory path built by Expand
+func join(dir, name string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// unescape removes the backslashes quoting characters of a pattern
+func unescape(pattern string) string {
+	if !strings.Contains(pattern, `\`) {
+		return pattern
+	}
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		sb.WriteByte(pattern[i])
+	}
+	return sb.String()
+}
@@ -0,0 +1,214 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+)
+
+// braceItem is one element of a word during brace expansion: a character of
+// unquoted literal text, or any other part, which is never split
+type braceItem struct {
+	c    byte
+	part parser.WordPart
+}
+
+func (it braceItem) is(c byte) bool {
+	return it.part == nil && it.c == c
+}
+
+// expandBraces performs brace expansion, turning a{b,c}d into abd acd and
+// {1..3} into 1 2 3. Only unquoted braces take part, so "{a,b}" and \{a,b}
+// are left alone, while expansions such as {$a,$b} are kept whole inside
+// the alternatives and expanded later.
+func expandBraces(word *parser.Word) []*parser.Word {
+	var items []braceItem
+	hasBrace := false
+	for _, part := range word.Parts {
+		lit, ok := part.(*parser.Literal)
+		if !ok {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+		for i := 0; i < len(lit.Value); i++ {
+			items = append(items, braceItem{c: lit.Value[i]})
+		}
+		hasBrace = hasBrace || strings.Contains(lit.Value, "{")
+	}
+	if !hasBrace {
+		return []*parser.Word{word}
+	}
+
+	expanded := braceExpand(items)
+	words := make([]*parser.Word, 0, len(expanded))
+	for _, items := range expanded {
+		words = append(words, braceWord(items))
+	}
+	return words
+}
+
+// braceExpand expands the first valid brace expression of items, then the
+// rest of each result in turn
+func braceExpand(items []braceItem) [][]braceItem {
+	for open := range items {
+		if !items[open].is('{') {
+			continue
+		}
+		end, commas := braceClose(items, open)
+		if end < 0 {
+			continue
+		}
+
+		var alternatives [][]braceItem
+		if len(commas) > 0 {
+			start := open + 1
+			for _, comma := range append(commas, end) {
+				alternatives = append(alternatives, items[start:comma])
+				start = comma + 1
+			}
+		} else if alternatives = braceSequence(items[open+1 : end]); alternatives == nil {
+			// Not an expression, e.g. {a} or {}; look for a later one
+			continue
+		}
+
+		var results [][]braceItem
+		for _, alternative := range alternatives {
+			combined := append(append(append([]braceItem{}, items[:open]...), alternative...), items[end+1:]...)
+			results = append(results, braceExpand(combined)...)
+		}
+		return results
+	}
+	return [][]braceItem{items}
+}
+
+// braceClose returns the index of the brace closing the one at open and the
+// positions of the commas directly inside it, or -1 if it is not closed
+func braceClose(items []braceItem, open int) (int, []int) {
+	depth := 0
+	var commas []int
+	for i := open + 1; i < len(items); i++ {
+		switch {
+		case items[i].is('{'):
+			depth++
+		case items[i].is('}'):
+			if depth == 0 {
+				return i, commas
+			}
+			depth--
+		case items[i].is(',') && depth == 0:
+			commas = append(commas, i)
+		}
+	}
+	return -1, nil
+}
+
+// braceSequence expands the body of a sequence expression, x..y or x..y..step,
+// where x and y are both integers or both single characters. Integers are
+// zero-padded to the same width when either of them has a leading zero. It
+// returns nil if the body is not a sequence.
+func braceSequence(body []braceItem) [][]braceItem {
+	var sb strings.Builder
+	for _, it := range body {
+		if it.part != nil {
+			return nil
+		}
+		sb.WriteByte(it.c)
+	}
+
+	bounds := strings.Split(sb.String(), "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil
+	}
+
+	step := 1
+	if len(bounds) == 3 {
+		n, err := strconv.Atoi(bounds[2])
+		if err != nil {
+			return nil
+		}
+		step = max(n, -n, 1)
+	}
+
+	var values []string
+	if start, err := strconv.Atoi(bounds[0]); err == nil {
+		end, err := strconv.Atoi(bounds[1])
+		if err != nil {
+			return nil
+		}
+		width := 0
+		if hasLeadingZero(bounds[0]) || hasLeadingZero(bounds[1]) {
+			width = max(len(bounds[0]), len(bounds[1]))
+		}
+		for _, n := range sequence(start, end, step) {
+			values = append(values, padNumber(n, width))
+		}
+	} else if len(bounds[0]) == 1 && len(bounds[1]) == 1 {
+		for _, n := range sequence(int(bounds[0][0]), int(bounds[1][0]), step) {
+			values = append(values, string(rune(n)))
+		}
+	} else {
+		return nil
+	}
+
+	alternatives := make([][]braceItem, len(values))
+	for i, value := range values {
+		for j := 0; j < len(value); j++ {
+			alternatives[i] = append(alternatives[i], braceItem{c: value[j]})
+		}
+	}
+	return alternatives
+}
+
+// sequence counts from start to end, in either direction
+func sequence(start, end, step int) []int {
+	var values []int
+	if start <= end {
+		for n := start; n <= end; n += step {
+			values = append(values, n)
+		}
+	} else {
+		for n := start; n >= end; n -= step {
+			values = append(values, n)
+		}
+	}
+	return values
+}
+
+func hasLeadingZero(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+// padNumber formats n with zeros after any sign up to width characters
+func padNumber(n, width int) string {
+	s := strconv.Itoa(n)
+	if len(s) >= width {
+		return s
+	}
+	if n < 0 {
+		return "-" + strings.Repeat("0", width-len(s)) + s[1:]
+	}
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// braceWord turns items back into a word, merging characters into literals
+func braceWord(items []braceItem) *parser.Word {
+	word := &parser.Word{}
+	var literal strings.Builder
+	for _, it := range items {
+		if it.part == nil {
+			literal.WriteByte(it.c)
+			continue
+		}
+		if literal.Len() > 0 {
+			word.Parts = append(word.Parts, &parser.Literal{Value: literal.String()})
+			literal.Reset()
+		}
+		word.Parts = append(word.Parts, it.part)
+	}
+	if literal.Len() > 0 {
+		word.Parts = append(word.Parts, &parser.Literal{Value: literal.String()})
+	}
+	return word
+}
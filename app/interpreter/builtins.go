@@ -0,0 +1,473 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/arith"
+	"github.com/codecrafters-io/shell-starter-go/app/commands"
+	"github.com/codecrafters-io/shell-starter-go/app/utils"
+)
+
+// shellFlags lists the single-letter options understood by set, in the
+// order they are shown in $-
+const shellFlags = "fiux"
+
+// longOptions maps the option names accepted by set -o to their flags
+var longOptions = map[string]byte{
+	"noglob":  'f',
+	"nounset": 'u',
+	"xtrace":  'x',
+}
+
+// shoptNames lists the options understood by shopt, in the order it shows
+// them
+var shoptNames = []string{
+	"dotglob",
+	"failglob",
+	"interactive_comments",
+	"nocaseglob",
+	"nullglob",
+}
+
+// execBuiltin runs the builtins that work on the interpreter's own state
+// rather than on the process. It reports false if args does not name one of
+// them, leaving the command to utils.ExecuteCommand.
+func (in *Interpreter) execBuiltin(args []string, streams utils.Streams) (int, bool) {
+	switch args[0] {
+	case commands.SET:
+		return in.setImpl(args[1:], streams.Stdout, streams.Stderr), true
+	case commands.SHOPT:
+		return in.shoptImpl(args[1:], streams.Stdout, streams.Stderr), true
+	case commands.EXIT:
+		return in.exitImpl(args[1:], streams.Stderr), true
+	case commands.BREAK:
+		return in.loopControlImpl(ctlBreak, args, streams.Stderr), true
+	case commands.CONTINUE:
+		return in.loopControlImpl(ctlContinue, args, streams.Stderr), true
+	case commands.RETURN:
+		return in.returnImpl(args[1:], streams.Stderr), true
+	case commands.LOCAL:
+		return in.localImpl(args[1:], streams.Stderr), true
+	case commands.LET:
+		return in.letImpl(args[1:], streams.Stderr), true
+	case commands.READ:
+		return in.readImpl(args[1:], streams), true
+	}
+	return 0, false
+}
+
+// exitImpl implements exit. Rather than ending the process it unwinds the
+// commands being run, so that exit in a subshell only ends the subshell.
+// Without an argument the status is the one of the last command.
+func (in *Interpreter) exitImpl(args []string, stderr io.Writer) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "%s: too many arguments\n", commands.EXIT)
+		return 1
+	}
+
+	status := in.status
+	if len(args) == 1 {
+		status = commands.ExitImpl(&args[0], stderr)
+	}
+	in.ctl = ctlExit
+	return status
+}
+
+// loopControlImpl implements break and continue, which leave or restart
+// the n-th enclosing loop
+func (in *Interpreter) loopControlImpl(kind controlKind, args []string, stderr io.Writer) int {
+	if len(args) > 2 {
+		fmt.Fprintf(stderr, "%s: too many arguments\n", args[0])
+		return 1
+	}
+
+	n := 1
+	if len(args) == 2 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s: numeric argument required\n", args[0], args[1])
+			return 1
+		}
+		if n < 1 {
+			fmt.Fprintf(stderr, "%s: %s: loop count out of range\n", args[0], args[1])
+			return 1
+		}
+	}
+
+	if in.loops == 0 {
+		fmt.Fprintf(stderr, "%s: only meaningful in a `for', `while', or `until' loop\n", args[0])
+		return 0
+	}
+	in.ctl = kind
+	in.ctlLoops = min(n, in.loops)
+	return 0
+}
+
+// returnImpl implements return, which ends the running function with the
+// given status, or the status of the last command
+func (in *Interpreter) returnImpl(args []string, stderr io.Writer) int {
+	if in.calls == 0 {
+		fmt.Fprintln(stderr, "return: can only `return' from a function")
+		return 1
+	}
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "%s: too many arguments\n", commands.RETURN)
+		return 1
+	}
+
+	status := in.status
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "return: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	in.ctl = ctlReturn
+	return status
+}
+
+// localImpl implements local, declaring variables local to the running
+// function, optionally with a value: local name[=value]...
+func (in *Interpreter) localImpl(args []string, stderr io.Writer) int {
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isName(name) {
+			fmt.Fprintf(stderr, "local: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		if !in.vars.Local(name) {
+			fmt.Fprintln(stderr, "local: can only be used in a function")
+			return 1
+		}
+		if hasValue {
+			in.vars.Set(name, value)
+		}
+	}
+	return status
+}
+
+// letImpl implements let, evaluating each argument as an arithmetic
+// expression. It succeeds when the last one is non-zero.
+func (in *Interpreter) letImpl(args []string, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "let: expression expected")
+		return 1
+	}
+
+	var value int64
+	for _, arg := range args {
+		var err error
+		value, err = arith.Eval(arg, in)
+		if err != nil {
+			fmt.Fprintf(stderr, "let: %v\n", err)
+			return 1
+		}
+	}
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+// readImpl implements read, which reads a line from stdin and splits it
+// into fields with IFS. Each name gets one field and the last one the rest
+// of the line; without names the line goes to REPLY, and with -a the fields
+// become the elements of an array. Unless -r is given a backslash escapes
+// the next character and joins lines. -d sets the delimiter ending the line
+// and -p a prompt shown when reading from a terminal.
+func (in *Interpreter) readImpl(args []string, streams utils.Streams) int {
+	raw := false
+	array, prompt := "", ""
+	delim := byte('\n')
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		flags := args[0][1:]
+		args = args[1:]
+		for i := 0; i < len(flags); i++ {
+			if flags[i] == 'r' {
+				raw = true
+				continue
+			}
+			if strings.IndexByte("adp", flags[i]) < 0 {
+				fmt.Fprintf(streams.Stderr, "read: -%c: invalid option\n", flags[i])
+				fmt.Fprintln(streams.Stderr, "read: usage: read [-r] [-a array] [-d delim] [-p prompt] [name ...]")
+				return 2
+			}
+			// The argument is the rest of the flags or the next argument
+			value := flags[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					fmt.Fprintf(streams.Stderr, "read: -%c: option requires an argument\n", flags[i])
+					return 2
+				}
+				value, args = args[0], args[1:]
+			}
+			switch flags[i] {
+			case 'a':
+				array = value
+			case 'd':
+				// An empty delimiter reads up to a NUL byte
+				delim = 0
+				if value != "" {
+					delim = value[0]
+				}
+			case 'p':
+				prompt = value
+			}
+			break
+		}
+	}
+
+	names := args
+	if array != "" {
+		names = []string{array}
+	}
+	for _, name := range names {
+		if !isName(name) {
+			fmt.Fprintf(streams.Stderr, "read: `%s': not a valid identifier\n", name)
+			return 1
+		}
+	}
+
+	if info, err := streams.Stdin.Stat(); err == nil && prompt != "" && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(streams.Stderr, prompt)
+	}
+	line, escaped, ok := readLine(streams.Stdin, delim, raw)
+
+	status := 0
+	if !ok {
+		status = 1
+	}
+	switch {
+	case array != "":
+		in.vars.SetArray(array, in.splitLine(line, escaped, 0))
+	case len(names) == 0:
+		in.Set("REPLY", line)
+	default:
+		fields := in.splitLine(line, escaped, len(names))
+		for i, name := range names {
+			value := ""
+			if i < len(fields) {
+				value = fields[i]
+			}
+			if err := in.Set(name, value); err != nil {
+				fmt.Fprintf(streams.Stderr, "read: %v\n", err)
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// readLine reads up to the delimiter one byte at a time, so that the rest
+// of the input is left to the commands that follow. Unless raw is set, a
+// backslash escapes the next character, which is then marked in escaped,
+// and a backslash before the delimiter joins the next line. It reports false
+// if the input ended before the delimiter.
+func readLine(r io.Reader, delim byte, raw bool) (string, []bool, bool) {
+	var line []byte
+	var escaped []bool
+	buf := make([]byte, 1)
+	backslash := false
+	for {
+		if n, err := r.Read(buf); n == 0 || err != nil {
+			return string(line), escaped, false
+		}
+		c := buf[0]
+		switch {
+		case backslash:
+			backslash = false
+			if c == delim {
+				continue
+			}
+			line = append(line, c)
+			escaped = append(escaped, true)
+		case c == delim:
+			return string(line), escaped, true
+		case c == '\\' && !raw:
+			backslash = true
+		default:
+			line = append(line, c)
+			escaped = append(escaped, false)
+		}
+	}
+}
+
+// setImpl implements set: without arguments it lists the shell variables,
+// -o/+o and -x/+x style arguments turn options on and off, and any
+// remaining arguments (or all after --) become the positional parameters.
+func (in *Interpreter) setImpl(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		for _, name := range in.vars.Names() {
+			value, _ := in.vars.Get(name)
+			fmt.Fprintf(stdout, "%s=%s\n", name, shellQuote(value))
+		}
+		return 0
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" {
+			in.positional = append([]string{}, args[i+1:]...)
+			return 0
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			in.positional = append([]string{}, args[i:]...)
+			return 0
+		}
+
+		on := arg[0] == '-'
+		if arg[1:] == "o" {
+			if i+1 == len(args) {
+				in.printOptions(stdout, on)
+				return 0
+			}
+			i++
+			flag, ok := longOptions[args[i]]
+			if !ok {
+				fmt.Fprintf(stderr, "set: %s: invalid option name\n", args[i])
+				return 2
+			}
+			in.flags[flag] = on
+			continue
+		}
+
+		for _, flag := range []byte(arg[1:]) {
+			if flag == 'i' || !strings.ContainsRune(shellFlags, rune(flag)) {
+				fmt.Fprintf(stderr, "set: %c%c: invalid option\n", arg[0], flag)
+				return 2
+			}
+			in.flags[flag] = on
+		}
+	}
+	return 0
+}
+
+// shoptImpl implements shopt: -s and -u turn the named options on and off,
+// -p prints them as shopt commands and -q only reports through the exit
+// status whether they are all on. Without names the options are listed.
+func (in *Interpreter) shoptImpl(args []string, stdout, stderr io.Writer) int {
+	var mode byte
+	quiet, reusable := false, false
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range []byte(args[0][1:]) {
+			switch flag {
+			case 's', 'u':
+				if mode != 0 && mode != flag {
+					fmt.Fprintln(stderr, "shopt: cannot set and unset shell options simultaneously")
+					return 1
+				}
+				mode = flag
+			case 'q':
+				quiet = true
+			case 'p':
+				reusable = true
+			default:
+				fmt.Fprintf(stderr, "shopt: -%c: invalid option\n", flag)
+				fmt.Fprintln(stderr, "shopt: usage: shopt [-pqsu] [optname ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+
+	for _, name := range args {
+		if !slices.Contains(shoptNames, name) {
+			fmt.Fprintf(stderr, "shopt: %s: invalid shell option name\n", name)
+			return 1
+		}
+	}
+
+	if mode != 0 && len(args) > 0 {
+		for _, name := range args {
+			in.shopts[name] = mode == 's'
+		}
+		return 0
+	}
+
+	names := args
+	if len(names) == 0 {
+		names = shoptNames
+	}
+	status := 0
+	for _, name := range names {
+		on := in.shopts[name]
+		// With -s or -u and no names, only the options in that state are listed
+		if len(args) == 0 && mode != 0 && on != (mode == 's') {
+			continue
+		}
+		if !on {
+			status = 1
+		}
+		switch {
+		case quiet:
+		case reusable:
+			flag := "-u"
+			if on {
+				flag = "-s"
+			}
+			fmt.Fprintf(stdout, "shopt %s %s\n", flag, name)
+		default:
+			state := "off"
+			if on {
+				state = "on"
+			}
+			fmt.Fprintf(stdout, "%-15s\t%s\n", name, state)
+		}
+	}
+	if len(args) == 0 {
+		return 0
+	}
+	return status
+}
+
+// printOptions lists the long options, either as a table (set -o) or as
+// commands that would recreate them (set +o)
+func (in *Interpreter) printOptions(stdout io.Writer, table bool) {
+	names := make([]string, 0, len(longOptions))
+	for name := range longOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		on := in.flags[longOptions[name]]
+		if table {
+			state := "off"
+			if on {
+				state = "on"
+			}
+			fmt.Fprintf(stdout, "%-15s\t%s\n", name, state)
+		} else if on {
+			fmt.Fprintf(stdout, "set -o %s\n", name)
+		} else {
+			fmt.Fprintf(stdout, "set +o %s\n", name)
+		}
+	}
+}
+
+// shellQuote quotes s so that the shell would read it back as a single word
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`|&;()<>*?[]{}~#=!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,589 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/codecrafters-io/shell-starter-go/app/arith"
+	"github.com/codecrafters-io/shell-starter-go/app/commands"
+	"github.com/codecrafters-io/shell-starter-go/app/glob"
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+	"github.com/codecrafters-io/shell-starter-go/app/utils"
+)
+
+// execList runs the items of a list in order and returns the status of the
+// last one. Items terminated by & are started in the background.
+func (in *Interpreter) execList(list *parser.List) int {
+	status := 0
+	for _, item := range list.Items {
+		if item.Background {
+			in.execBackground(item.AndOr)
+			status = 0
+			continue
+		}
+		status = in.execAndOr(item.AndOr)
+		if in.ctl != ctlNone {
+			break
+		}
+	}
+	return status
+}
+
+// execBackground starts an and-or list without waiting for it. Like any
+// shell without job control, it reads from /dev/null instead of the terminal.
+func (in *Interpreter) execBackground(andOr *parser.AndOr) {
+	streams := in.streams
+	devNull, err := os.Open(os.DevNull)
+	if err == nil {
+		streams.Stdin = devNull
+	}
+
+	job := startBackgroundJob(andOr)
+	in.lastJob = job
+
+	// Like a subshell, the job cannot change the state of the shell
+	sub := in.subshell().withStreams(streams)
+	sub.job = job
+	go func() {
+		sub.execAndOr(andOr)
+		job.finish()
+		if devNull != nil {
+			devNull.Close()
+		}
+	}()
+}
+
+// execAndOr runs the pipelines of an and-or list. A pipeline joined with &&
+// only runs if the previous status was 0 and one joined with || only if it
+// was non-zero, so skipped pipelines leave the status untouched exactly as in
+// bash. It returns the status of the last pipeline that ran.
+func (in *Interpreter) execAndOr(andOr *parser.AndOr) int {
+	status := in.execPipeline(andOr.Pipelines[0])
+	for i, op := range andOr.Ops {
+		if in.ctl != ctlNone {
+			break
+		}
+		if (op == parser.AND) != (status == 0) {
+			continue
+		}
+		status = in.execPipeline(andOr.Pipelines[i+1])
+	}
+	return status
+}
+
+// execPipeline runs all commands of a pipeline at the same time, connecting
+// the stdout of each command to the stdin of the next one with an OS pipe. It
+// waits for every command to finish and returns the exit status of the last
+// one, inverted if the pipeline was negated with !.
+func (in *Interpreter) execPipeline(pipeline *parser.Pipeline) int {
+	status := in.runPipeline(pipeline.Commands)
+	if pipeline.Negated {
+		if status == 0 {
+			status = 1
+		} else {
+			status = 0
+		}
+	}
+	in.status = status
+	return status
+}
+
+// runPipeline runs each command of a multi-command pipeline in a subshell
+// of its own, as bash does without the lastpipe option
+func (in *Interpreter) runPipeline(commands []parser.Command) int {
+	if len(commands) == 1 {
+		return in.execCommand(commands[0])
+	}
+
+	statuses := make([]int, len(commands))
+	var wg sync.WaitGroup
+
+	stdin := in.streams.Stdin
+	for i, cmd := range commands {
+		stdout := in.streams.Stdout
+		var nextStdin *os.File
+
+		// Every command but the last writes into a pipe read by the next one
+		if i < len(commands)-1 {
+			r, w, err := os.Pipe()
+			if err != nil {
+				fmt.Fprintln(in.streams.Stderr, err)
+				statuses[len(commands)-1] = 1
+				if stdin != in.streams.Stdin {
+					stdin.Close()
+				}
+				break
+			}
+			stdout = w
+			nextStdin = r
+		}
+
+		streams := in.streams
+		streams.Stdin, streams.Stdout = stdin, stdout
+		stage := in.subshell().withStreams(streams)
+		wg.Add(1)
+		go func(i int, cmd parser.Command) {
+			defer wg.Done()
+			statuses[i] = stage.execCommand(cmd)
+
+			// Release our pipe ends so the neighbouring commands see EOF or EPIPE
+			if stage.streams.Stdout != in.streams.Stdout {
+				stage.streams.Stdout.Close()
+			}
+			if stage.streams.Stdin != in.streams.Stdin {
+				stage.streams.Stdin.Close()
+			}
+		}(i, cmd)
+
+		stdin = nextStdin
+	}
+
+	wg.Wait()
+	return statuses[len(commands)-1]
+}
+
+// execCommand runs a single pipeline stage
+func (in *Interpreter) execCommand(cmd parser.Command) int {
+	switch c := cmd.(type) {
+	case *parser.SimpleCommand:
+		return in.execSimpleCommand(c)
+	case *parser.Subshell:
+		return in.subshell().execGroup(c.Body, c.Redirects)
+	case *parser.BraceGroup:
+		return in.execGroup(c.Body, c.Redirects)
+	case *parser.ArithCommand:
+		return in.execArith(c)
+	case *parser.IfClause:
+		return in.execIf(c)
+	case *parser.WhileClause:
+		return in.execWhile(c)
+	case *parser.ForClause:
+		return in.execFor(c)
+	case *parser.ArithForClause:
+		return in.execArithFor(c)
+	case *parser.CaseClause:
+		return in.execCase(c)
+	case *parser.FunctionDef:
+		in.functions[c.Name] = c
+		return 0
+	}
+	return 0
+}
+
+// execSimpleCommand expands the words of a command, applies its
+// redirections and runs it
+func (in *Interpreter) execSimpleCommand(cmd *parser.SimpleCommand) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	// A background job whose process was killed runs no further commands
+	if in.job != nil {
+		if sig := in.job.killed(); sig != 0 {
+			in.ctl = ctlExit
+			return 128 + int(sig)
+		}
+	}
+
+	in.substRan = false
+	args, err := in.expandArgs(cmd.Args)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+
+	restore, err := in.redirect(cmd.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	// Without a command the assignments set shell variables, and the status
+	// is the one of the last command substitution
+	if len(args) == 0 {
+		if _, err := in.assign(cmd.Assigns, false); err != nil {
+			fmt.Fprintln(in.streams.Stderr, err)
+			return 1
+		}
+		if in.substRan {
+			return in.status
+		}
+		return 0
+	}
+
+	// Otherwise they only last as long as the command runs, exported to it
+	assigns, err := in.assign(cmd.Assigns, true)
+	defer func() {
+		for i := len(assigns) - 1; i >= 0; i-- {
+			assigns[i]()
+		}
+	}()
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	if in.flags['x'] {
+		in.trace(args)
+	}
+
+	// A job running a builtin or a function has no process of its own
+	if in.job != nil && (in.IsFunction(args[0]) || slices.Contains(commands.COMMANDS, args[0])) {
+		in.job.standIn()
+	}
+
+	// Functions come first, then the builtins and finally external commands
+	streams := in.procSubstStreams()
+	var status int
+	if fn, ok := in.functions[args[0]]; ok {
+		status = in.callFunction(fn, args[1:])
+	} else if status, ok = in.execBuiltin(args, streams); !ok {
+		status = utils.ExecuteCommand(args, streams, in)
+	}
+	return status
+}
+
+// expandArgs expands the words of a simple command into its arguments. The
+// NAME=value arguments of local are expanded like assignments, without
+// field splitting or pathname expansion.
+func (in *Interpreter) expandArgs(words []*parser.Word) ([]string, error) {
+	if len(words) == 0 {
+		return in.expandWords(words)
+	}
+	if lit, ok := words[0].Lit(); !ok || lit != commands.LOCAL {
+		return in.expandWords(words)
+	}
+
+	args := []string{commands.LOCAL}
+	for _, word := range words[1:] {
+		if parser.IsAssignment(word) {
+			arg, err := in.expandWord(word)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			continue
+		}
+		fields, err := in.expandWords([]*parser.Word{word})
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fields...)
+	}
+	return args, nil
+}
+
+// assign expands and performs the NAME=value assignments of a simple
+// command in order, so that each value can use the ones before it. With
+// temp set the variables are exported and the returned functions restore
+// the variables they replaced. Assignments are traced by set -x.
+func (in *Interpreter) assign(words []*parser.Word, temp bool) ([]func(), error) {
+	var restores []func()
+	for _, word := range words {
+		text, err := in.expandWord(word)
+		if err != nil {
+			return restores, err
+		}
+		name, value, _ := strings.Cut(text, "=")
+		if in.flags['x'] {
+			fmt.Fprintf(in.streams.Stderr, "+ %s=%s\n", name, shellQuote(value))
+		}
+		if temp {
+			restores = append(restores, in.vars.SetTemp(name, value))
+		} else if err := in.Set(name, value); err != nil {
+			return restores, err
+		}
+	}
+	return restores, nil
+}
+
+// trace prints a command to stderr before it runs, as enabled by set -x
+func (in *Interpreter) trace(args []string) {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	fmt.Fprintf(in.streams.Stderr, "+ %s\n", strings.Join(quoted, " "))
+}
+
+// execIf runs the body of the first branch of an if command whose
+// condition succeeds, or its else branch. Without either it returns 0.
+func (in *Interpreter) execIf(clause *parser.IfClause) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(clause.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	for i, cond := range clause.Conds {
+		if in.execList(cond) == 0 {
+			return in.execList(clause.Bodies[i])
+		}
+	}
+	if clause.Else != nil {
+		return in.execList(clause.Else)
+	}
+	return 0
+}
+
+// execWhile runs a while or until loop. It returns the status of the last
+// command of the body, or 0 if the body never ran.
+func (in *Interpreter) execWhile(clause *parser.WhileClause) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(clause.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	in.loops++
+	defer func() { in.loops-- }()
+
+	status := 0
+	for {
+		cond := in.execList(clause.Cond)
+		if in.ctl != ctlNone {
+			if in.endIteration() {
+				break
+			}
+			continue
+		}
+		if (cond == 0) == clause.Until {
+			break
+		}
+
+		status = in.execList(clause.Body)
+		if in.ctl != ctlNone && in.endIteration() {
+			break
+		}
+	}
+	return status
+}
+
+// execFor runs a for loop over its expanded words, or over the positional
+// parameters when the list was left out
+func (in *Interpreter) execFor(clause *parser.ForClause) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(clause.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	words := slices.Clone(in.positional)
+	if clause.In {
+		words, err = in.expandWords(clause.Words)
+		if err != nil {
+			fmt.Fprintln(in.streams.Stderr, err)
+			return 1
+		}
+	}
+
+	in.loops++
+	defer func() { in.loops-- }()
+
+	status := 0
+	for _, word := range words {
+		if err := in.Set(clause.Name, word); err != nil {
+			fmt.Fprintln(in.streams.Stderr, err)
+			return 1
+		}
+		status = in.execList(clause.Body)
+		if in.ctl != ctlNone && in.endIteration() {
+			break
+		}
+	}
+	return status
+}
+
+// execArithFor runs a for ((init; cond; step)) loop
+func (in *Interpreter) execArithFor(clause *parser.ArithForClause) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(clause.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	in.loops++
+	defer func() { in.loops-- }()
+
+	if _, err := in.expandArith(clause.Init); err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for {
+		cond, err := in.expandWord(clause.Cond)
+		if err == nil && strings.TrimSpace(cond) != "" {
+			var value int64
+			value, err = arith.Eval(cond, in)
+			if err == nil && value == 0 {
+				break
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(in.streams.Stderr, err)
+			return 1
+		}
+
+		status = in.execList(clause.Body)
+		if in.ctl != ctlNone && in.endIteration() {
+			break
+		}
+
+		if _, err := in.expandArith(clause.Step); err != nil {
+			fmt.Fprintln(in.streams.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+// execCase runs the body of the first item of a case command with a
+// pattern matching the word. The item's terminator decides whether the
+// next body runs as well (;&) or the search goes on (;;&).
+func (in *Interpreter) execCase(clause *parser.CaseClause) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(clause.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	word, err := in.expandWord(clause.Word)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	fallThrough := false
+	for _, item := range clause.Items {
+		if !fallThrough {
+			matched, err := in.matchCase(word, item.Patterns)
+			if err != nil {
+				fmt.Fprintln(in.streams.Stderr, err)
+				return 1
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		status = in.execList(item.Body)
+		if in.ctl != ctlNone {
+			break
+		}
+		switch item.Terminator {
+		case parser.CASE_FALLTHROUGH:
+			fallThrough = true
+		case parser.CASE_CONTINUE:
+			fallThrough = false
+		default:
+			return status
+		}
+	}
+	return status
+}
+
+// matchCase reports whether any of the patterns of a case item matches the
+// word
+func (in *Interpreter) matchCase(word string, patterns []*parser.Word) (bool, error) {
+	for _, pattern := range patterns {
+		expanded, err := in.expandPattern(pattern)
+		if err != nil {
+			return false, err
+		}
+		if glob.Match(expanded, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// endIteration handles a pending break or continue when it reaches the end
+// of an iteration of a loop. It reports whether the loop has to stop, which
+// is also the case for an exit.
+func (in *Interpreter) endIteration() bool {
+	switch in.ctl {
+	case ctlBreak, ctlContinue:
+		stop := in.ctl == ctlBreak || in.ctlLoops > 1
+		in.ctlLoops--
+		if in.ctlLoops == 0 {
+			in.ctl = ctlNone
+		}
+		return stop
+	}
+	return in.ctl != ctlNone
+}
+
+// callFunction runs a function with args as its positional parameters and
+// a scope of its own for local variables
+func (in *Interpreter) callFunction(fn *parser.FunctionDef, args []string) int {
+	positional, loops := in.positional, in.loops
+	in.positional, in.loops = args, 0
+	in.vars.pushScope()
+	in.calls++
+	defer func() {
+		in.positional, in.loops = positional, loops
+		in.vars.popScope()
+		in.calls--
+	}()
+
+	status := in.execCommand(fn.Body)
+	if in.ctl == ctlReturn {
+		in.ctl = ctlNone
+	}
+	return status
+}
+
+// execArith runs a ((...)) command, which succeeds when the expression is
+// non-zero
+func (in *Interpreter) execArith(cmd *parser.ArithCommand) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(cmd.Redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	value, err := in.expandArith(cmd.Expr)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+// execGroup runs the body of a grouping command with its redirections
+func (in *Interpreter) execGroup(body *parser.List, redirects []*parser.Redirect) int {
+	defer in.finishProcSubsts(len(in.procSubsts))
+
+	restore, err := in.redirect(redirects)
+	if err != nil {
+		fmt.Fprintln(in.streams.Stderr, err)
+		return 1
+	}
+	defer restore()
+
+	return in.execList(body)
+}
@@ -0,0 +1,641 @@
+package interpreter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/codecrafters-io/shell-starter-go/app/arith"
+	"github.com/codecrafters-io/shell-starter-go/app/glob"
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+)
+
+// chunk is a piece of expanded text. Quoted is set for text that came from
+// quotes or escapes, which has to be matched literally when the text is used
+// as a pattern. Split is set for the result of an unquoted expansion, which
+// is subject to word splitting. FieldBreak is set when the chunk starts a
+// new field, as each positional parameter of "$@" does.
+type chunk struct {
+	text       string
+	quoted     bool
+	split      bool
+	fieldBreak bool
+}
+
+// field is one word resulting from expansion
+type field []chunk
+
+// String returns the text of the field with its quotes removed
+func (f field) String() string {
+	var sb strings.Builder
+	for _, c := range f {
+		sb.WriteString(c.text)
+	}
+	return sb.String()
+}
+
+// pattern returns the field as a glob pattern in which quoted characters are
+// escaped. It reports false if no unquoted character is special, in which
+// case the field is not subject to pathname expansion.
+func (f field) pattern() (string, bool) {
+	var sb strings.Builder
+	hasMeta := false
+	for _, c := range f {
+		if c.quoted {
+			sb.WriteString(glob.QuoteMeta(c.text))
+			continue
+		}
+		if glob.HasMeta(c.text) {
+			hasMeta = true
+		}
+		sb.WriteString(c.text)
+	}
+	return sb.String(), hasMeta
+}
+
+// expandWords expands every word into its final fields
+func (in *Interpreter) expandWords(words []*parser.Word) ([]string, error) {
+	args := make([]string, 0, len(words))
+	for _, word := range words {
+		for _, braced := range expandBraces(word) {
+			fields, err := in.expandFields(braced)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				paths, err := in.expandPathname(f)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, paths...)
+			}
+		}
+	}
+	return args, nil
+}
+
+// expandPathname replaces a field holding an unquoted glob pattern with the
+// matching paths. Without matches the field is kept as it is, unless the
+// nullglob or failglob options are set.
+func (in *Interpreter) expandPathname(f field) ([]string, error) {
+	pattern, ok := f.pattern()
+	if !ok || in.flags['f'] {
+		return []string{f.String()}, nil
+	}
+
+	paths := glob.Expand(pattern, glob.Options{
+		Dir:     in.dir,
+		DotGlob: in.shopts["dotglob"],
+		NoCase:  in.shopts["nocaseglob"],
+	})
+	switch {
+	case len(paths) > 0:
+		return paths, nil
+	case in.shopts["failglob"]:
+		return nil, fmt.Errorf("no match: %s", f.String())
+	case in.shopts["nullglob"]:
+		return nil, nil
+	}
+	return []string{f.String()}, nil
+}
+
+// expandFields expands a word into fields, splitting the results of
+// unquoted expansions with IFS
+func (in *Interpreter) expandFields(word *parser.Word) ([]field, error) {
+	chunks, err := in.expandWordParts(word)
+	if err != nil {
+		return nil, err
+	}
+	return in.splitFields(chunks), nil
+}
+
+// expandWord expands a word into a single string, as needed for redirection
+// targets and the arguments of expansion operators. Fields that "$@" would
+// produce are joined with spaces.
+func (in *Interpreter) expandWord(word *parser.Word) (string, error) {
+	chunks, err := in.expandWordParts(word)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, c := range chunks {
+		if c.fieldBreak {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(c.text)
+	}
+	return sb.String(), nil
+}
+
+// expandPattern expands a word into a glob pattern in which every quoted
+// character is escaped so that it only matches itself
+func (in *Interpreter) expandPattern(word *parser.Word) (string, error) {
+	chunks, err := in.expandWordParts(word)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, c := range chunks {
+		if c.fieldBreak {
+			sb.WriteByte(' ')
+		}
+		if c.quoted {
+			sb.WriteString(glob.QuoteMeta(c.text))
+		} else {
+			sb.WriteString(c.text)
+		}
+	}
+	return sb.String(), nil
+}
+
+// expandParts expands word parts into chunks. Quoted is set when the parts
+// are inside double quotes.
+func (in *Interpreter) expandParts(parts []parser.WordPart, quoted bool) ([]chunk, error) {
+	var chunks []chunk
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *parser.Literal:
+			chunks = append(chunks, chunk{text: p.Value, quoted: quoted})
+		case *parser.Escaped:
+			chunks = append(chunks, chunk{text: p.Value, quoted: true})
+		case *parser.SingleQuoted:
+			chunks = append(chunks, chunk{text: p.Value, quoted: true})
+		case *parser.DoubleQuoted:
+			inner, err := in.expandParts(p.Parts, true)
+			if err != nil {
+				return nil, err
+			}
+			// Quotes make an empty word a field, except around a "$@"
+			// that expanded to no field at all
+			if !onlyLists(p.Parts) {
+				chunks = append(chunks, chunk{quoted: true})
+			}
+			chunks = append(chunks, inner...)
+		case *parser.ParamExp:
+			if isList(p) {
+				chunks = append(chunks, in.expandList(p, quoted)...)
+				continue
+			}
+			value, err := in.expandParam(p)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chunk{text: value, quoted: quoted, split: !quoted})
+		case *parser.ArithExp:
+			value, err := in.expandArith(p.Expr)
+			if err != nil {
+				return nil, err
+			}
+			text := strconv.FormatInt(value, 10)
+			chunks = append(chunks, chunk{text: text, quoted: quoted, split: !quoted})
+		case *parser.ProcSubst:
+			path, err := in.startProcSubst(p)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chunk{text: path, quoted: quoted, split: !quoted})
+		case *parser.CmdSubst:
+			output, err := in.captureOutput(p.Body)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, chunk{text: output, quoted: quoted, split: !quoted})
+		}
+	}
+	return chunks, nil
+}
+
+// captureOutput runs a command substitution and returns what it wrote to
+// stdout, without trailing newlines. Its status becomes $?.
+func (in *Interpreter) captureOutput(body *parser.List) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	// Read concurrently so that commands producing more output than the pipe
+	// can buffer do not block
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&output, r)
+		r.Close()
+		close(done)
+	}()
+
+	streams := in.streams
+	streams.Stdout = w
+	sub := in.subshell().withStreams(streams)
+	sub.job = nil
+	in.status = sub.execList(body)
+	in.substRan = true
+	w.Close()
+	<-done
+
+	return strings.TrimRight(output.String(), "\n"), nil
+}
+
+// expandList expands $@ and ${name[@]} to one field per positional
+// parameter or array element, except for "$*" and "${name[*]}" which join
+// them with the first character of IFS
+func (in *Interpreter) expandList(exp *parser.ParamExp, quoted bool) []chunk {
+	values := in.positional
+	star := exp.Name == "*"
+	if exp.Index != nil {
+		values, _ = in.vars.GetArray(exp.Name)
+		star = listIndex(exp) == "*"
+	}
+
+	if star && quoted {
+		sep := in.ifs()
+		if sep != "" {
+			sep = sep[:1]
+		}
+		return []chunk{{text: strings.Join(values, sep), quoted: true}}
+	}
+
+	chunks := make([]chunk, len(values))
+	for i, value := range values {
+		chunks[i] = chunk{text: value, quoted: quoted, split: !quoted, fieldBreak: i > 0}
+	}
+	return chunks
+}
+
+// listIndex returns the subscript of ${name[@]} and ${name[*]}, or "" when
+// exp does not expand all the elements of an array
+func listIndex(exp *parser.ParamExp) string {
+	if exp.Index == nil || len(exp.Index.Parts) != 1 {
+		return ""
+	}
+	if lit, ok := exp.Index.Parts[0].(*parser.Literal); ok && (lit.Value == "@" || lit.Value == "*") {
+		return lit.Value
+	}
+	return ""
+}
+
+// isList reports whether exp is a plain $@, $*, ${name[@]} or ${name[*]}
+func isList(exp *parser.ParamExp) bool {
+	if exp.Op != "" || exp.Length {
+		return false
+	}
+	if exp.Index != nil {
+		return listIndex(exp) != ""
+	}
+	return exp.Name == "@" || exp.Name == "*"
+}
+
+// onlyLists reports whether the parts of a quoted string are all $@ or
+// ${name[@]}
+func onlyLists(parts []parser.WordPart) bool {
+	if len(parts) == 0 {
+		return false
+	}
+	for _, part := range parts {
+		exp, ok := part.(*parser.ParamExp)
+		if !ok || !isList(exp) || exp.Name == "*" || listIndex(exp) == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// expandParam returns the value of a parameter expansion with its operator
+// applied
+func (in *Interpreter) expandParam(exp *parser.ParamExp) (string, error) {
+	value, set, err := in.paramValue(exp)
+	if err != nil {
+		return "", err
+	}
+	if !set && in.flags['u'] && !isDefaultOp(exp.Op) && exp.Name != "@" && exp.Name != "*" && listIndex(exp) == "" {
+		return "", in.fatal(fmt.Errorf("%s: unbound variable", exp.Name))
+	}
+	if exp.Length {
+		if exp.Name == "@" || exp.Name == "*" {
+			return strconv.Itoa(len(in.positional)), nil
+		}
+		if listIndex(exp) != "" {
+			elements, _ := in.vars.GetArray(exp.Name)
+			return strconv.Itoa(len(elements)), nil
+		}
+		return strconv.Itoa(utf8.RuneCountInString(value)), nil
+	}
+
+	if isDefaultOp(exp.Op) {
+		return in.expandDefault(exp, value, set)
+	}
+	switch exp.Op {
+	case "":
+		return value, nil
+	case ":":
+		return in.expandSubstring(exp, value)
+	case "/", "//", "/#", "/%":
+		return in.expandReplace(exp, value)
+	}
+
+	pattern, err := in.expandPattern(exp.Arg)
+	if err != nil {
+		return "", err
+	}
+	switch exp.Op {
+	case "#", "##":
+		return removePrefix(value, pattern, exp.Op == "##"), nil
+	case "%", "%%":
+		return removeSuffix(value, pattern, exp.Op == "%%"), nil
+	}
+	return convertCase(value, pattern, exp.Op), nil
+}
+
+// paramValue returns the value of the parameter or array element exp
+// refers to and whether it is set. All the elements of an array are joined
+// with spaces.
+func (in *Interpreter) paramValue(exp *parser.ParamExp) (string, bool, error) {
+	if exp.Index == nil {
+		value, set := in.Get(exp.Name)
+		return value, set, nil
+	}
+	if listIndex(exp) != "" {
+		elements, _ := in.vars.GetArray(exp.Name)
+		return strings.Join(elements, " "), len(elements) > 0, nil
+	}
+	index, err := in.arrayIndex(exp)
+	if err != nil {
+		return "", false, err
+	}
+	value, set := in.vars.GetIndex(exp.Name, index)
+	return value, set, nil
+}
+
+// arrayIndex evaluates the subscript of ${name[index]}. A negative index
+// counts from the end of the array.
+func (in *Interpreter) arrayIndex(exp *parser.ParamExp) (int, error) {
+	index, err := in.expandNumber(exp.Index)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 {
+		elements, _ := in.vars.GetArray(exp.Name)
+		index += len(elements)
+	}
+	return index, nil
+}
+
+// assignParam assigns the variable or array element exp refers to, for the
+// ${name=word} operator
+func (in *Interpreter) assignParam(exp *parser.ParamExp, value string) error {
+	if exp.Index == nil {
+		return in.Set(exp.Name, value)
+	}
+	if sub := listIndex(exp); sub != "" {
+		return fmt.Errorf("%s[%s]: bad array subscript", exp.Name, sub)
+	}
+	index, err := in.arrayIndex(exp)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return fmt.Errorf("%s[%d]: bad array subscript", exp.Name, index)
+	}
+	return in.vars.SetIndex(exp.Name, index, value)
+}
+
+// fatal returns err after making it end the shell unless the shell is
+// interactive, as POSIX requires for ${name?word} and set -u errors
+func (in *Interpreter) fatal(err error) error {
+	if !in.flags['i'] {
+		in.ctl = ctlExit
+	}
+	return err
+}
+
+// isDefaultOp reports whether op is one of the operators testing whether a
+// parameter is set: -, =, ? and +, with or without a colon
+func isDefaultOp(op string) bool {
+	switch strings.TrimPrefix(op, ":") {
+	case "-", "=", "?", "+":
+		return true
+	}
+	return false
+}
+
+// expandDefault applies the POSIX default (-), assign (=), error (?) and
+// alternate (+) operators. With a leading colon the operators treat an empty
+// value like an unset one.
+func (in *Interpreter) expandDefault(exp *parser.ParamExp, value string, set bool) (string, error) {
+	missing := !set || (strings.HasPrefix(exp.Op, ":") && value == "")
+	switch strings.TrimPrefix(exp.Op, ":") {
+	case "-":
+		if missing {
+			return in.expandWord(exp.Arg)
+		}
+	case "=":
+		if missing {
+			arg, err := in.expandWord(exp.Arg)
+			if err != nil {
+				return "", err
+			}
+			if err := in.assignParam(exp, arg); err != nil {
+				return "", err
+			}
+			return arg, nil
+		}
+	case "?":
+		if missing {
+			msg, err := in.expandWord(exp.Arg)
+			if err != nil {
+				return "", err
+			}
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			return "", in.fatal(fmt.Errorf("%s: %s", exp.Name, msg))
+		}
+	case "+":
+		if missing {
+			return "", nil
+		}
+		return in.expandWord(exp.Arg)
+	}
+	return value, nil
+}
+
+// expandSubstring implements ${name:offset:length}. A negative offset counts
+// from the end of the value and a negative length is an offset from the end.
+func (in *Interpreter) expandSubstring(exp *parser.ParamExp, value string) (string, error) {
+	runes := []rune(value)
+
+	offset, err := in.expandNumber(exp.Arg)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 {
+		offset += len(runes)
+	}
+	if offset < 0 || offset > len(runes) {
+		return "", nil
+	}
+
+	end := len(runes)
+	if exp.Arg2 != nil {
+		length, err := in.expandNumber(exp.Arg2)
+		if err != nil {
+			return "", err
+		}
+		if length < 0 {
+			end = len(runes) + length
+			if end < offset {
+				return "", fmt.Errorf("%d: substring expression < 0", length)
+			}
+		} else if offset+length < end {
+			end = offset + length
+		}
+	}
+	return string(runes[offset:end]), nil
+}
+
+// expandArith expands a word and evaluates it as an arithmetic expression
+func (in *Interpreter) expandArith(word *parser.Word) (int64, error) {
+	expr, err := in.expandWord(word)
+	if err != nil {
+		return 0, err
+	}
+	return arith.Eval(expr, in)
+}
+
+// expandNumber expands a word holding an arithmetic expression whose value
+// is used as an index
+func (in *Interpreter) expandNumber(word *parser.Word) (int, error) {
+	n, err := in.expandArith(word)
+	return int(n), err
+}
+
+// expandReplace implements ${name/pattern/string} and its //, /# and /%
+// variants, which replace every match, a prefix match and a suffix match
+func (in *Interpreter) expandReplace(exp *parser.ParamExp, value string) (string, error) {
+	pattern, err := in.expandPattern(exp.Arg)
+	if err != nil {
+		return "", err
+	}
+	replacement := ""
+	if exp.Arg2 != nil {
+		if replacement, err = in.expandWord(exp.Arg2); err != nil {
+			return "", err
+		}
+	}
+	return replacePattern(value, pattern, replacement, exp.Op), nil
+}
+
+// removePrefix removes the shortest (or longest) prefix matching pattern
+func removePrefix(value, pattern string, longest bool) string {
+	runes := []rune(value)
+	for i := range len(runes) + 1 {
+		n := i
+		if longest {
+			n = len(runes) - i
+		}
+		if glob.Match(pattern, string(runes[:n])) {
+			return string(runes[n:])
+		}
+	}
+	return value
+}
+
+// removeSuffix removes the shortest (or longest) suffix matching pattern
+func removeSuffix(value, pattern string, longest bool) string {
+	runes := []rune(value)
+	for i := range len(runes) + 1 {
+		start := len(runes) - i
+		if longest {
+			start = i
+		}
+		if glob.Match(pattern, string(runes[start:])) {
+			return string(runes[:start])
+		}
+	}
+	return value
+}
+
+// replacePattern replaces the longest matches of pattern in value. Op is /
+// for the first match, // for every match, /# for a match at the start and
+// /% for a match at the end.
+func replacePattern(value, pattern, replacement, op string) string {
+	runes := []rune(value)
+
+	switch op {
+	case "/#":
+		if end := longestMatch(runes, 0, pattern); end >= 0 {
+			return replacement + string(runes[end:])
+		}
+		return value
+	case "/%":
+		for start := 0; start <= len(runes); start++ {
+			if glob.Match(pattern, string(runes[start:])) {
+				return string(runes[:start]) + replacement
+			}
+		}
+		return value
+	}
+
+	if pattern == "" {
+		return value
+	}
+
+	var sb strings.Builder
+	i := 0
+	for i < len(runes) {
+		end := longestMatch(runes, i, pattern)
+		if end <= i {
+			sb.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		sb.WriteString(replacement)
+		i = end
+		if op == "/" {
+			break
+		}
+	}
+	sb.WriteString(string(runes[i:]))
+	return sb.String()
+}
+
+// longestMatch returns the end of the longest match of pattern starting at
+// start, or -1 if there is none
+func longestMatch(runes []rune, start int, pattern string) int {
+	for end := len(runes); end >= start; end-- {
+		if glob.Match(pattern, string(runes[start:end])) {
+			return end
+		}
+	}
+	return -1
+}
+
+// convertCase implements the ^, ^^, , and ,, operators that upper or lower
+// case the first or every character matching pattern (any character if empty)
+func convertCase(value, pattern, op string) string {
+	if pattern == "" {
+		pattern = "?"
+	}
+	runes := []rune(value)
+	for i, r := range runes {
+		if i > 0 && len(op) == 1 {
+			break
+		}
+		if !glob.Match(pattern, string(r)) {
+			continue
+		}
+		if op[0] == '^' {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
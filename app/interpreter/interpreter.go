@@ -0,0 +1,229 @@
+package interpreter
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+	"github.com/codecrafters-io/shell-starter-go/app/utils"
+)
+
+// Interpreter walks the AST produced by the parser and executes it
+type Interpreter struct {
+	streams utils.Streams
+	vars    *Variables
+	dir     string // the working directory, private to each subshell
+
+	name       string          // $0
+	positional []string        // $1, $2, ...
+	flags      map[byte]bool   // single-letter options shown in $-
+	shopts     map[string]bool // options set with shopt
+	status     int             // $?
+	substRan   bool            // a command substitution ran in the current command
+	pid        int             // $$
+
+	job     *backgroundJob // the background job this interpreter runs, if any
+	lastJob *backgroundJob // the most recently started background job, for $!
+
+	procSubsts []*procSubst // process substitutions of the running command
+	functions  map[string]*parser.FunctionDef
+	calls      int // depth of the function calls being run
+
+	loops    int         // depth of the loops being run
+	ctl      controlKind // pending break, continue or exit
+	ctlLoops int         // loops left to unwind by a pending break or continue
+}
+
+// controlKind identifies a change of control flow raised by a builtin. It
+// unwinds the commands being run up to the construct that handles it.
+type controlKind int
+
+const (
+	ctlNone controlKind = iota
+	ctlBreak
+	ctlContinue
+	ctlReturn
+	ctlExit
+)
+
+// New creates an interpreter attached to the standard streams of the
+// process. Name becomes $0 and args the positional parameters.
+func New(name string, args []string) *Interpreter {
+	dir, _ := os.Getwd()
+	return &Interpreter{
+		streams:    utils.Streams{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr},
+		vars:       newVariables(),
+		dir:        dir,
+		name:       name,
+		positional: args,
+		flags:      make(map[byte]bool),
+		shopts:     map[string]bool{"interactive_comments": true},
+		functions:  make(map[string]*parser.FunctionDef),
+		pid:        os.Getpid(),
+	}
+}
+
+// Run executes a parsed command list and returns its exit status
+func (in *Interpreter) Run(list *parser.List) int {
+	return in.execList(list)
+}
+
+// Parse parses input into a command list. A # starting a word begins a
+// comment unless the shell is interactive and interactive_comments is off.
+func (in *Interpreter) Parse(input string) (*parser.List, error) {
+	if in.flags['i'] && !in.shopts["interactive_comments"] {
+		return parser.ParseNoComments(input)
+	}
+	return parser.Parse(input)
+}
+
+// Exited reports whether the exit builtin ended the shell. The status
+// returned by Run is then the one to exit with.
+func (in *Interpreter) Exited() bool {
+	return in.ctl == ctlExit
+}
+
+// SetFlag turns a single-letter option on or off, e.g. 'i' for an
+// interactive shell
+func (in *Interpreter) SetFlag(flag byte, on bool) {
+	in.flags[flag] = on
+}
+
+// SetStatus sets $?, used when a line fails before it can run
+func (in *Interpreter) SetStatus(status int) {
+	in.status = status
+}
+
+// Get returns the value of a parameter: a special parameter like $? or $#, a
+// positional parameter or a shell variable
+func (in *Interpreter) Get(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(in.status), true
+	case "$":
+		return strconv.Itoa(in.pid), true
+	case "!":
+		if in.lastJob == nil {
+			return "", false
+		}
+		if pid := in.lastJob.wait(); pid != 0 {
+			return strconv.Itoa(pid), true
+		}
+		return "", false
+	case "#":
+		return strconv.Itoa(len(in.positional)), true
+	case "@", "*":
+		return strings.Join(in.positional, " "), len(in.positional) > 0
+	case "0":
+		return in.name, true
+	case "-":
+		return in.flagString(), true
+	}
+
+	if n, err := strconv.Atoi(name); err == nil {
+		if n < 1 || n > len(in.positional) {
+			return "", false
+		}
+		return in.positional[n-1], true
+	}
+	return in.vars.Get(name)
+}
+
+// Set assigns a shell variable. Special and positional parameters cannot be
+// assigned this way.
+func (in *Interpreter) Set(name, value string) error {
+	if !isName(name) {
+		return fmt.Errorf("$%s: cannot assign in this way", name)
+	}
+	return in.vars.Set(name, value)
+}
+
+// Environ returns the exported variables as NAME=value pairs
+func (in *Interpreter) Environ() []string {
+	return in.vars.Environ()
+}
+
+// Getwd returns the working directory of the interpreter. Subshells have
+// their own, so it can differ from the one of the process.
+func (in *Interpreter) Getwd() string {
+	return in.dir
+}
+
+// Chdir changes the working directory of the interpreter
+func (in *Interpreter) Chdir(dir string) error {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(in.dir, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+	in.dir = dir
+	return nil
+}
+
+// IsFunction reports whether name is a shell function
+func (in *Interpreter) IsFunction(name string) bool {
+	_, ok := in.functions[name]
+	return ok
+}
+
+// ProcessStarted records the PID of an external command started by the
+// interpreter, so that killing the background job it belongs to kills it too
+func (in *Interpreter) ProcessStarted(pid int) {
+	if in.job != nil {
+		in.job.started(pid)
+	}
+}
+
+// ProcessExited records that an external command reported by
+// ProcessStarted has ended
+func (in *Interpreter) ProcessExited(pid int) {
+	if in.job != nil {
+		in.job.exited(pid)
+	}
+}
+
+// flagString returns the active single-letter options in a stable order
+func (in *Interpreter) flagString() string {
+	var sb strings.Builder
+	for _, flag := range []byte(shellFlags) {
+		if in.flags[flag] {
+			sb.WriteByte(flag)
+		}
+	}
+	return sb.String()
+}
+
+// subshell returns a copy of the interpreter with its own variables,
+// options, positional parameters and working directory, so that changes made
+// by the commands it runs do not leak back
+func (in *Interpreter) subshell() *Interpreter {
+	sub := *in
+	sub.vars = in.vars.clone()
+	sub.positional = slices.Clone(in.positional)
+	sub.flags = maps.Clone(in.flags)
+	sub.shopts = maps.Clone(in.shopts)
+	sub.functions = maps.Clone(in.functions)
+	sub.procSubsts = nil
+	return &sub
+}
+
+// withStreams returns a copy of the interpreter that runs against the given
+// streams, used for pipeline stages and redirected commands
+func (in *Interpreter) withStreams(streams utils.Streams) *Interpreter {
+	sub := *in
+	sub.streams = streams
+	return &sub
+}
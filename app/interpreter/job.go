@@ -0,0 +1,147 @@
+package interpreter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+)
+
+// JobProcessEnv is set in the environment of the process standing for a
+// background job. The shell started with it only waits for its stdin to be
+// closed, which happens when the job ends.
+const JobProcessEnv = "SHELL_JOB_PROCESS"
+
+// backgroundJob tracks an and-or list started with &. Its PID, $!, is the
+// PID of the command it runs when the job is a single external command.
+// Other jobs run inside the shell, with no process of their own, so they
+// get a stand-in process whose PID becomes $! as soon as the job starts.
+// Only a signal that kills the stand-in reaches the job: it is passed on to
+// the commands the job is running and the job runs no more. Stopping the
+// stand-in, or asking ps about it, does not tell anything about the job.
+type backgroundJob struct {
+	once  sync.Once
+	ready chan struct{} // closed once the PID is known
+	pid   int           // 0 if no process could be started
+	stdin io.Closer     // closing it ends the stand-in process
+
+	mu      sync.Mutex
+	running map[int]bool   // PIDs of the external commands being run
+	signal  syscall.Signal // the signal that killed the stand-in, if any
+}
+
+// startBackgroundJob creates the job running andOr. A job made of a single
+// simple command gets its PID when the command starts, unless it turns out
+// to be a builtin or a function; any other job starts its stand-in now.
+func startBackgroundJob(andOr *parser.AndOr) *backgroundJob {
+	j := &backgroundJob{ready: make(chan struct{}), running: make(map[int]bool)}
+	if !isSimpleJob(andOr) {
+		j.standIn()
+	}
+	return j
+}
+
+// isSimpleJob reports whether an and-or list is a single simple command
+func isSimpleJob(andOr *parser.AndOr) bool {
+	if len(andOr.Pipelines) != 1 || len(andOr.Pipelines[0].Commands) != 1 {
+		return false
+	}
+	_, ok := andOr.Pipelines[0].Commands[0].(*parser.SimpleCommand)
+	return ok
+}
+
+// standIn starts the stand-in process of the job unless its PID is known
+func (j *backgroundJob) standIn() {
+	j.once.Do(func() {
+		defer close(j.ready)
+
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		cmd := exec.Command(exe)
+		cmd.Env = append(os.Environ(), JobProcessEnv+"=1")
+		// Keep it out of the terminal's process group, away from Ctrl-C
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return
+		}
+		if err := cmd.Start(); err != nil {
+			stdin.Close()
+			return
+		}
+		j.pid, j.stdin = cmd.Process.Pid, stdin
+
+		go func() {
+			var exitErr *exec.ExitError
+			if err := cmd.Wait(); errors.As(err, &exitErr) {
+				if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+					j.kill(ws.Signal())
+				}
+			}
+		}()
+	})
+}
+
+// wait blocks until the PID of the job is known and returns it
+func (j *backgroundJob) wait() int {
+	<-j.ready
+	return j.pid
+}
+
+// kill records that the stand-in was killed by sig and passes the signal on
+// to the commands the job is running
+func (j *backgroundJob) kill(sig syscall.Signal) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.signal = sig
+	for pid := range j.running {
+		syscall.Kill(pid, sig)
+	}
+}
+
+// killed returns the signal that killed the job, or 0 if it is alive
+func (j *backgroundJob) killed() syscall.Signal {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.signal
+}
+
+// started records an external command run by the job. The first one gives
+// its PID to a job without a stand-in, and a command started after the job
+// was killed gets the signal right away.
+func (j *backgroundJob) started(pid int) {
+	j.once.Do(func() {
+		j.pid = pid
+		close(j.ready)
+	})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.signal != 0 {
+		syscall.Kill(pid, j.signal)
+	}
+	j.running[pid] = true
+}
+
+// exited records that an external command run by the job has ended
+func (j *backgroundJob) exited(pid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.running, pid)
+}
+
+// finish ends the stand-in process once the job is done. A job that never
+// got a PID, because its command could not be run, gets one that ends at
+// once.
+func (j *backgroundJob) finish() {
+	j.standIn()
+	if j.stdin != nil {
+		j.stdin.Close()
+	}
+}
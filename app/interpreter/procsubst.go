@@ -0,0 +1,76 @@
+package interpreter
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+	"github.com/codecrafters-io/shell-starter-go/app/utils"
+)
+
+// procSubst is a running process substitution. File is the shell's end of
+// the pipe, which the command using the /dev/fd path inherits.
+type procSubst struct {
+	file *os.File
+	done chan struct{}
+}
+
+// startProcSubst starts the command of a process substitution in the
+// background, connected to a pipe, and returns the /dev/fd path naming the
+// other end of the pipe
+func (in *Interpreter) startProcSubst(p *parser.ProcSubst) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+
+	// The command reads what is written to >(...) and writes what is read
+	// from <(...)
+	streams := in.streams
+	file, end := r, w
+	if p.Write {
+		streams.Stdin = r
+		file, end = w, r
+	} else {
+		streams.Stdout = w
+	}
+
+	sub := in.subshell().withStreams(streams)
+	sub.job = nil
+
+	ps := &procSubst{file: file, done: make(chan struct{})}
+	go func() {
+		sub.execList(p.Body)
+		end.Close()
+		close(ps.done)
+	}()
+	in.procSubsts = append(in.procSubsts, ps)
+
+	return "/dev/fd/" + strconv.Itoa(int(file.Fd())), nil
+}
+
+// procSubstStreams returns the streams of the interpreter including the
+// descriptors of the running process substitutions, so that external
+// commands inherit them under the numbers used in their /dev/fd paths
+func (in *Interpreter) procSubstStreams() utils.Streams {
+	streams := in.streams
+	for _, ps := range in.procSubsts {
+		streams.Set(int(ps.file.Fd()), ps.file)
+	}
+	return streams
+}
+
+// finishProcSubsts closes the shell's end of the process substitutions
+// started after the first n, then waits for their commands to finish
+func (in *Interpreter) finishProcSubsts(n int) {
+	if len(in.procSubsts) <= n {
+		return
+	}
+	for _, ps := range in.procSubsts[n:] {
+		ps.file.Close()
+	}
+	for _, ps := range in.procSubsts[n:] {
+		<-ps.done
+	}
+	in.procSubsts = in.procSubsts[:n]
+}
@@ -0,0 +1,83 @@
+package interpreter
+
+import (
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+	"github.com/codecrafters-io/shell-starter-go/app/utils"
+)
+
+// redirect applies the given redirections on top of the interpreter's
+// streams, so that compound commands keep running in the current shell. It
+// returns a function restoring the previous streams and closing every file
+// that was opened.
+func (in *Interpreter) redirect(redirects []*parser.Redirect) (func(), error) {
+	if len(redirects) == 0 {
+		return func() {}, nil
+	}
+
+	streams := in.streams
+	var opened []*os.File
+	closeAll := func() {
+		for _, file := range opened {
+			file.Close()
+		}
+	}
+
+	for _, redirect := range redirects {
+		if redirect.Body != nil {
+			body, err := in.expandHeredoc(redirect.Body)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			file, err := utils.HeredocImpl(&streams, redirect.Fd, body)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			opened = append(opened, file)
+			continue
+		}
+
+		target, err := in.expandWord(redirect.Target)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		file, err := utils.RedirectionImpl(&streams, in.dir, redirect.Fd, redirect.Op, target)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		if file != nil {
+			opened = append(opened, file)
+		}
+	}
+
+	saved := in.streams
+	in.streams = streams
+	return func() {
+		in.streams = saved
+		closeAll()
+	}, nil
+}
+
+// expandHeredoc expands the body of a here-document. It is treated as text
+// in double quotes, so it is neither split nor globbed.
+func (in *Interpreter) expandHeredoc(body *parser.Word) (string, error) {
+	chunks, err := in.expandParts(body.Parts, true)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, c := range chunks {
+		if c.fieldBreak {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(c.text)
+	}
+	return sb.String(), nil
+}
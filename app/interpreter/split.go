@@ -0,0 +1,156 @@
+package interpreter
+
+import "strings"
+
+// ifs returns the characters word splitting breaks fields on: $IFS, or
+// space, tab and newline when it is unset
+func (in *Interpreter) ifs() string {
+	if ifs, ok := in.vars.Get("IFS"); ok {
+		return ifs
+	}
+	return " \t\n"
+}
+
+// isIFSWhitespace reports whether c is a whitespace character of IFS.
+// Runs of them delimit a single field and are trimmed around fields.
+func isIFSWhitespace(c byte, ifs string) bool {
+	return (c == ' ' || c == '\t' || c == '\n') && strings.IndexByte(ifs, c) >= 0
+}
+
+// splitFields splits the chunks of an expanded word into fields following
+// the POSIX rules. Only the chunks resulting from unquoted expansions are
+// split, on the characters of IFS: a run of IFS whitespace ends a field,
+// and so does each other IFS character along with the whitespace around it,
+// which can produce empty fields as in a::b. A field made only of unquoted
+// expansions that all expanded to nothing is dropped.
+func (in *Interpreter) splitFields(chunks []chunk) []field {
+	ifs := in.ifs()
+
+	var fields []field
+	var current field
+	// delimited records how the last field ended while no text has been
+	// added to the next one: 0 when it did not just end, ' ' after IFS
+	// whitespace and ':' after another IFS character
+	var delimited byte
+	flush := func(force bool) {
+		for _, c := range current {
+			if c.quoted || c.text != "" {
+				force = true
+				break
+			}
+		}
+		if force {
+			fields = append(fields, current)
+		}
+		current = nil
+	}
+
+	for _, c := range chunks {
+		if c.fieldBreak {
+			flush(false)
+			delimited = 0
+		}
+		if !c.split || ifs == "" {
+			current = append(current, c)
+			if c.quoted || c.text != "" {
+				delimited = 0
+			}
+			continue
+		}
+
+		start := 0 // start of the text not yet added to current
+		for i := 0; i < len(c.text); i++ {
+			if strings.IndexByte(ifs, c.text[i]) < 0 {
+				delimited = 0
+				continue
+			}
+			if i > start {
+				current = append(current, chunk{text: c.text[start:i]})
+			}
+			start = i + 1
+
+			switch {
+			case isIFSWhitespace(c.text[i], ifs):
+				// Leading whitespace is ignored, and so is whitespace
+				// following a delimiter
+				if delimited == 0 && len(current) > 0 {
+					flush(false)
+					delimited = ' '
+				}
+			case delimited == ' ':
+				// Whitespace and the character after it form one delimiter
+				delimited = ':'
+			default:
+				flush(true)
+				delimited = ':'
+			}
+		}
+		if start < len(c.text) {
+			current = append(current, chunk{text: c.text[start:]})
+		}
+	}
+	flush(false)
+
+	return fields
+}
+
+// splitLine splits a line read by the read builtin into at most n fields,
+// or into as many fields as needed when n is 0. The last field takes the
+// rest of the line, without the IFS whitespace around it. Characters marked
+// in escaped were preceded by a backslash and never delimit fields.
+func (in *Interpreter) splitLine(line string, escaped []bool, n int) []string {
+	ifs := in.ifs()
+	isDelim := func(i int) bool {
+		return !escaped[i] && strings.IndexByte(ifs, line[i]) >= 0
+	}
+	isSpace := func(i int) bool {
+		return !escaped[i] && isIFSWhitespace(line[i], ifs)
+	}
+
+	// next returns the field starting at i and the position after the
+	// delimiter ending it
+	next := func(i int) (string, int) {
+		start := i
+		for i < len(line) && !isDelim(i) {
+			i++
+		}
+		field := line[start:i]
+		for i < len(line) && isSpace(i) {
+			i++
+		}
+		if i < len(line) && isDelim(i) {
+			i++
+			for i < len(line) && isSpace(i) {
+				i++
+			}
+		}
+		return field, i
+	}
+
+	i := 0
+	for i < len(line) && isSpace(i) {
+		i++
+	}
+
+	var fields []string
+	for i < len(line) {
+		if n > 0 && len(fields) == n-1 {
+			// The last field keeps the delimiters inside it, unless only
+			// the delimiter ending a single field is left
+			field, end := next(i)
+			if end < len(line) {
+				end = len(line)
+				for end > i && isSpace(end-1) {
+					end--
+				}
+				field = line[i:end]
+			}
+			fields = append(fields, field)
+			break
+		}
+		var field string
+		field, i = next(i)
+		fields = append(fields, field)
+	}
+	return fields
+}
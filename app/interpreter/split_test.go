@@ -0,0 +1,128 @@
+package interpreter
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+)
+
+// newTestInterpreter returns an interpreter with the given variables, where
+// a nil IFS leaves it unset
+func newTestInterpreter(t *testing.T, ifs *string, vars map[string]string, args ...string) *Interpreter {
+	t.Helper()
+	in := New("sh", args)
+	delete(in.vars.vars, "IFS")
+	if ifs != nil {
+		in.vars.Set("IFS", *ifs)
+	}
+	for name, value := range vars {
+		in.vars.Set(name, value)
+	}
+	return in
+}
+
+func ptr(s string) *string { return &s }
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		name string
+		ifs  *string
+		vars map[string]string
+		args []string
+		word string
+		want []string
+	}{
+		{"default IFS", nil, map[string]string{"a": "  x  y\t\nz "}, nil, "$a", []string{"x", "y", "z"}},
+		{"default IFS joins text around", nil, map[string]string{"a": " x "}, nil, "A${a}B", []string{"A", "x", "B"}},
+		{"colon", ptr(":"), map[string]string{"a": "x:y"}, nil, "$a", []string{"x", "y"}},
+		{"colon empty fields", ptr(":"), map[string]string{"a": ":x::y:"}, nil, "$a", []string{"", "x", "", "y"}},
+		{"colon trailing text", ptr(":"), map[string]string{"a": ":x::y:"}, nil, "${a}z", []string{"", "x", "", "y", "z"}},
+		{"colon keeps spaces", ptr(":"), map[string]string{"a": " x :y"}, nil, "$a", []string{" x ", "y"}},
+		{"space and colon", ptr(" :"), map[string]string{"a": " : x : "}, nil, "$a", []string{"", "x"}},
+		{"space and colon merge", ptr(" :"), map[string]string{"a": "x : y  z"}, nil, "$a", []string{"x", "y", "z"}},
+		{"empty IFS", ptr(""), map[string]string{"a": " x y "}, nil, "$a", []string{" x y "}},
+		{"empty unquoted", nil, map[string]string{"e": ""}, nil, "$e", nil},
+		{"unset unquoted", nil, nil, nil, "$nope", nil},
+		{"only whitespace", nil, map[string]string{"a": "   "}, nil, "$a", nil},
+		{"empty quoted", nil, map[string]string{"e": ""}, nil, `"$e"`, []string{""}},
+		{"empty quoted then field", nil, map[string]string{"e": "", "a": " x"}, nil, `"$e"$a`, []string{"", "x"}},
+		{"quoted not split", nil, map[string]string{"a": " x  y "}, nil, `"$a"`, []string{" x  y "}},
+		{"literal not split", ptr("x"), nil, nil, "axb", []string{"axb"}},
+		{"quoted at", nil, nil, []string{"a b", "", "c"}, `"$@"`, []string{"a b", "", "c"}},
+		{"quoted at without parameters", nil, nil, nil, `"$@"`, nil},
+		{"unquoted at", nil, nil, []string{"a b", "c"}, "$@", []string{"a", "b", "c"}},
+		{"quoted star", nil, nil, []string{"a b", "c"}, `"$*"`, []string{"a b c"}},
+		{"quoted star with IFS", ptr(":-"), nil, []string{"a", "b"}, `"$*"`, []string{"a:b"}},
+		{"quoted star with empty IFS", ptr(""), nil, []string{"a", "b"}, `"$*"`, []string{"ab"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newTestInterpreter(t, tt.ifs, tt.vars, tt.args...)
+			list, err := parser.Parse("cmd " + tt.word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			word := list.Items[0].AndOr.Pipelines[0].Commands[0].(*parser.SimpleCommand).Args[1]
+
+			fields, err := in.expandFields(word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range fields {
+				got = append(got, f.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%s: got %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLine(t *testing.T) {
+	tests := []struct {
+		name string
+		ifs  *string
+		line string
+		n    int
+		want []string
+	}{
+		{"default IFS", nil, "  a  b c ", 0, []string{"a", "b", "c"}},
+		{"fewer names than fields", nil, "  a  b  c  ", 2, []string{"a", "b  c"}},
+		{"one name", nil, "  a  b  ", 1, []string{"a  b"}},
+		{"more names than fields", nil, "a", 3, []string{"a"}},
+		{"colon", ptr(":"), ":a::b:", 0, []string{"", "a", "", "b"}},
+		{"colon last field drops one delimiter", ptr(":"), "x:y:", 2, []string{"x", "y"}},
+		{"colon last field keeps more", ptr(":"), "x:y::", 2, []string{"x", "y::"}},
+		{"colon single name", ptr(":"), "x:", 1, []string{"x"}},
+		{"space and colon", ptr(" :"), " x : y z : ", 2, []string{"x", "y z :"}},
+		{"empty IFS", ptr(""), "  p  ", 1, []string{"  p  "}},
+		{"empty line", nil, "", 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := newTestInterpreter(t, tt.ifs, nil)
+			got := in.splitLine(tt.line, make([]bool, len(tt.line)), tt.n)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%q into %d: got %q, want %q", tt.line, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitLineEscaped(t *testing.T) {
+	in := newTestInterpreter(t, nil, nil)
+	// read a b <<< '  a\ b  c\  ' leaves the escaped spaces in the fields
+	line, escaped, ok := readLine(strings.NewReader("  a\\ b  c\\  \n"), '\n', false)
+	if !ok {
+		t.Fatal("readLine reported a missing delimiter")
+	}
+	got := in.splitLine(line, escaped, 2)
+	if want := []string{"a b", "c "}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package interpreter
+
+import (
+	"os/user"
+
+	"github.com/codecrafters-io/shell-starter-go/app/parser"
+)
+
+// expandWordParts expands the parts of a whole word, performing tilde
+// expansion on its unquoted literal text first. A tilde prefix is expanded
+// at the start of the word and, in words that look like assignments, after
+// the = and after every :, as in PATH=~/bin:~bob/bin.
+func (in *Interpreter) expandWordParts(word *parser.Word) ([]chunk, error) {
+	assignment := parser.IsAssignment(word)
+
+	var chunks []chunk
+	for i, part := range word.Parts {
+		lit, ok := part.(*parser.Literal)
+		if !ok || (i > 0 && !assignment) {
+			expanded, err := in.expandParts([]parser.WordPart{part}, false)
+			if err != nil {
+				return nil, err
+			}
+			chunks = append(chunks, expanded...)
+			continue
+		}
+		last := i == len(word.Parts)-1
+		chunks = append(chunks, in.expandTildes(lit.Value, i == 0, assignment, last)...)
+	}
+	return chunks, nil
+}
+
+// expandTildes expands the tilde prefixes of unquoted literal text. AtStart
+// is set when the text starts the word and last when no other part follows
+// it, since a prefix running into a following quoted part is not expanded.
+func (in *Interpreter) expandTildes(text string, atStart, assignment, last bool) []chunk {
+	var chunks []chunk
+	start := 0 // start of the text not yet added to chunks
+	seenEquals := false
+
+	for i := 0; i < len(text); i++ {
+		eligible := i == 0 && atStart
+		if i > 0 && assignment {
+			switch text[i-1] {
+			case ':':
+				eligible = true
+			case '=':
+				eligible = atStart && !seenEquals
+				seenEquals = true
+			}
+		}
+		if !eligible || text[i] != '~' {
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && text[end] != '/' && !(assignment && text[end] == ':') {
+			end++
+		}
+		if end == len(text) && !last {
+			continue
+		}
+		dir, ok := in.tildeDir(text[i+1 : end])
+		if !ok {
+			continue
+		}
+
+		if i > start {
+			chunks = append(chunks, chunk{text: text[start:i]})
+		}
+		// The directory is used as it is, without splitting or globbing
+		chunks = append(chunks, chunk{text: dir, quoted: true})
+		start = end
+		i = end - 1
+	}
+	if start < len(text) {
+		chunks = append(chunks, chunk{text: text[start:]})
+	}
+	return chunks
+}
+
+// tildeDir returns the directory a tilde prefix stands for: the home
+// directory for ~, the one of the named user for ~user, $PWD for ~+ and
+// $OLDPWD for ~-
+func (in *Interpreter) tildeDir(name string) (string, bool) {
+	switch name {
+	case "":
+		if home, ok := in.Get("HOME"); ok {
+			return home, true
+		}
+		u, err := user.Current()
+		if err != nil {
+			return "", false
+		}
+		return u.HomeDir, true
+	case "+":
+		return in.Get("PWD")
+	case "-":
+		return in.Get("OLDPWD")
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
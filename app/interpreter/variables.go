@@ -0,0 +1,220 @@
+package interpreter
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// variable is a single shell variable. An indexed array keeps its elements
+// in array, and its first element stands for its value.
+type variable struct {
+	value    string
+	array    []string
+	exported bool
+}
+
+// Variables stores the shell variables. Variables inherited from the process
+// environment are exported to the commands the shell runs.
+type Variables struct {
+	vars map[string]*variable
+
+	// scopes holds, for each function call being run, the variables it
+	// declared local along with what they replaced (nil if they were unset)
+	scopes []map[string]*variable
+}
+
+// newVariables creates a variable store seeded with the process environment
+func newVariables() *Variables {
+	v := &Variables{vars: make(map[string]*variable)}
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && isName(name) {
+			v.vars[name] = &variable{value: value, exported: true}
+		}
+	}
+	return v
+}
+
+// clone returns an independent copy of the variables, used for subshells
+func (v *Variables) clone() *Variables {
+	c := &Variables{vars: copyVars(v.vars)}
+	for _, scope := range v.scopes {
+		c.scopes = append(c.scopes, copyVars(scope))
+	}
+	return c
+}
+
+func copyVars(vars map[string]*variable) map[string]*variable {
+	c := make(map[string]*variable, len(vars))
+	for name, variable := range vars {
+		if variable != nil {
+			copied := *variable
+			copied.array = slices.Clone(variable.array)
+			c[name] = &copied
+		} else {
+			c[name] = nil
+		}
+	}
+	return c
+}
+
+// pushScope starts the scope of a function call
+func (v *Variables) pushScope() {
+	v.scopes = append(v.scopes, make(map[string]*variable))
+}
+
+// popScope ends the scope of a function call, restoring the variables that
+// were declared local in it
+func (v *Variables) popScope() {
+	scope := v.scopes[len(v.scopes)-1]
+	v.scopes = v.scopes[:len(v.scopes)-1]
+	for name, saved := range scope {
+		if saved != nil {
+			v.vars[name] = saved
+		} else {
+			delete(v.vars, name)
+		}
+	}
+}
+
+// Local declares a variable local to the innermost function call, starting
+// out unset. Scoping is dynamic: functions called from there see the local
+// variable too. It reports false outside of a function.
+func (v *Variables) Local(name string) bool {
+	if len(v.scopes) == 0 {
+		return false
+	}
+	scope := v.scopes[len(v.scopes)-1]
+	if _, declared := scope[name]; declared {
+		return true
+	}
+	scope[name] = v.vars[name]
+	delete(v.vars, name)
+	return true
+}
+
+// Get returns the value of a variable and whether it is set
+func (v *Variables) Get(name string) (string, bool) {
+	variable, ok := v.vars[name]
+	if !ok {
+		return "", false
+	}
+	if variable.array != nil {
+		return v.GetIndex(name, 0)
+	}
+	return variable.value, true
+}
+
+// Set assigns a variable, keeping its exported attribute if it already
+// exists. Assigning an array sets its first element.
+func (v *Variables) Set(name, value string) error {
+	if variable, ok := v.vars[name]; ok {
+		if variable.array != nil {
+			return v.SetIndex(name, 0, value)
+		}
+		variable.value = value
+		return nil
+	}
+	v.vars[name] = &variable{value: value}
+	return nil
+}
+
+// SetTemp assigns an exported variable for the duration of a single
+// command. The returned function restores the variable it replaced.
+func (v *Variables) SetTemp(name, value string) (restore func()) {
+	saved, ok := v.vars[name]
+	v.vars[name] = &variable{value: value, exported: true}
+	return func() {
+		if ok {
+			v.vars[name] = saved
+		} else {
+			delete(v.vars, name)
+		}
+	}
+}
+
+// GetArray returns the elements of an array. A variable that is not an
+// array is taken as an array of one element.
+func (v *Variables) GetArray(name string) ([]string, bool) {
+	variable, ok := v.vars[name]
+	if !ok {
+		return nil, false
+	}
+	if variable.array != nil {
+		return variable.array, true
+	}
+	return []string{variable.value}, true
+}
+
+// GetIndex returns an element of an array and whether it is set
+func (v *Variables) GetIndex(name string, index int) (string, bool) {
+	elements, _ := v.GetArray(name)
+	if index < 0 || index >= len(elements) {
+		return "", false
+	}
+	return elements[index], true
+}
+
+// SetArray assigns an indexed array, replacing the variable's value
+func (v *Variables) SetArray(name string, elements []string) error {
+	array := append(make([]string, 0, len(elements)), elements...)
+	if variable, ok := v.vars[name]; ok {
+		variable.value, variable.array = "", array
+		return nil
+	}
+	v.vars[name] = &variable{array: array}
+	return nil
+}
+
+// SetIndex assigns an element of an array, turning a variable into an array
+// if needed. Elements are stored contiguously, so the elements before index
+// are set to empty values.
+func (v *Variables) SetIndex(name string, index int, value string) error {
+	elements, _ := v.GetArray(name)
+	elements = slices.Clone(elements)
+	for len(elements) <= index {
+		elements = append(elements, "")
+	}
+	elements[index] = value
+	return v.SetArray(name, elements)
+}
+
+// Names returns the names of all variables in sorted order
+func (v *Variables) Names() []string {
+	names := make([]string, 0, len(v.vars))
+	for name := range v.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns the exported variables as sorted NAME=value pairs. Arrays
+// cannot be exported.
+func (v *Variables) Environ() []string {
+	env := make([]string, 0, len(v.vars))
+	for name, variable := range v.vars {
+		if variable.exported && variable.array == nil {
+			env = append(env, name+"="+variable.value)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+// isName reports whether s is a valid variable name
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -1,20 +1,33 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
+	"github.com/codecrafters-io/shell-starter-go/app/interpreter"
 	"github.com/codecrafters-io/shell-starter-go/app/parser"
 	"github.com/codecrafters-io/shell-starter-go/app/prompt"
-	"github.com/codecrafters-io/shell-starter-go/app/utils"
 	"golang.org/x/term"
 )
 
 // Now update main() to integrate with eval() correctly
 func main() {
+	// Stand for a background job of another shell until it ends
+	if os.Getenv(interpreter.JobProcessEnv) != "" {
+		io.Copy(io.Discard, os.Stdin)
+		return
+	}
+
+	// With a script argument, run the script instead of reading commands
+	if len(os.Args) > 1 {
+		os.Exit(runScript(os.Args[1], os.Args[2:]))
+	}
+
 	// Setup signal handling first to ensure we always restore terminal state
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
@@ -29,6 +42,10 @@ func main() {
 		return
 	}
 
+	// Create the interpreter that runs every command line
+	interp := interpreter.New(os.Args[0], nil)
+	interp.SetFlag('i', true)
+
 	// Setup cleanup to happen in any exit case
 	cleanup := func() {
 		// First, restore the terminal to normal mode
@@ -51,8 +68,16 @@ func main() {
 
 	// Main shell loop
 	for {
+		// Continuation lines show $PS2, "> " unless it is set
+		prompter.Config.Continuation = "> "
+		if ps2, ok := interp.Get("PS2"); ok {
+			prompter.Config.Continuation = ps2
+		}
+
 		// Get input from user
-		input, err := prompter.ReadLine()
+		input, err := prompter.ReadCommand(func(input string) bool {
+			return incomplete(interp, input)
+		})
 		if err != nil {
 			if err == io.EOF {
 				// Handle Ctrl+D gracefully
@@ -69,7 +94,18 @@ func main() {
 		prompter.Close()
 
 		// Process the command
-		output, err := eval(input)
+		status, err := eval(interp, input)
+		if interp.Exited() {
+			fmt.Fprintln(os.Stderr, "exit")
+			os.Exit(status)
+		}
+
+		// Follow the shell's working directory so that completion and the
+		// terminal see the directory set by cd
+		os.Chdir(interp.Getwd())
+		if path, ok := interp.Get("PATH"); ok {
+			os.Setenv("PATH", path)
+		}
 
 		// After evaluation, reset to raw mode for our prompter
 		oldState, err2 := term.MakeRaw(int(os.Stdin.Fd()))
@@ -82,44 +118,71 @@ func main() {
 		// Now handle command results
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
-		} else if output != "" {
-			fmt.Println(output)
 		}
 	}
 }
 
-// Modified version of eval to work with our prompter approach
-func eval(input string) (string, error) {
-	tokens, stdoutFile, stderrFile, err := parser.ParseInput(input)
+// incomplete reports whether the input ends before the command does, such
+// as inside quotes, after | or && or inside an unfinished if or for
+func incomplete(interp *interpreter.Interpreter, input string) bool {
+	_, err := interp.Parse(input)
+	return errors.Is(err, parser.ErrIncomplete)
+}
+
+// eval parses the input into a command list and runs it, returning the exit
+// status of the last pipeline that ran
+func eval(interp *interpreter.Interpreter, input string) (int, error) {
+	list, err := interp.Parse(input)
 	if err != nil {
-		return "", err
+		interp.SetStatus(2)
+		return 2, err
 	}
 
-	// Handle empty input
-	if len(tokens) == 0 {
-		return "", nil
-	}
+	return interp.Run(list), nil
+}
 
-	originalStdout := os.Stdout
-	if stdoutFile != nil {
-		// Replace stdout with our file
-		os.Stdout = stdoutFile
-		defer func() {
-			stdoutFile.Close()
-			os.Stdout = originalStdout
-		}()
+// runScript runs a script file non-interactively, with args as its
+// positional parameters, and returns its exit status. Like bash it reads and
+// runs one complete command at a time, so the commands before a syntax
+// error still run.
+func runScript(path string, args []string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return 127
 	}
 
-	originalStderr := os.Stderr
-	if stderrFile != nil {
-		// Replace stderr with our file
-		os.Stderr = stderrFile
-		defer func() {
-			stderrFile.Close()
-			os.Stderr = originalStderr
-		}()
-	}
+	interp := interpreter.New(path, args)
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	status := 0
+	for line := 0; line < len(lines); {
+		// Read lines until they make up a complete command
+		input := ""
+		var list *parser.List
+		for line < len(lines) {
+			input += lines[line]
+			line++
+			if list, err = interp.Parse(input); !errors.Is(err, parser.ErrIncomplete) {
+				break
+			}
+		}
+		// A backslash ending the script is dropped rather than joining lines
+		if errors.Is(err, parser.ErrIncomplete) && strings.HasSuffix(input, "\\") {
+			list, err = interp.Parse(strings.TrimSuffix(input, "\\"))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: line %d: %v\n", path, line, err)
+			return 2
+		}
 
-	output, err := utils.ExecuteCommand(tokens)
-	return output, err
+		// Blank lines and comments leave the status alone
+		if len(list.Items) == 0 {
+			continue
+		}
+		status = interp.Run(list)
+		if interp.Exited() {
+			break
+		}
+	}
+	return status
 }
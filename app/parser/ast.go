@@ -0,0 +1,236 @@
+package parser
+
+// List is a sequence of and-or lists separated by ;, & or newlines
+type List struct {
+	Items []*ListItem
+}
+
+// ListItem is one and-or list of a List. Background is set when it was
+// terminated by & and should run asynchronously.
+type ListItem struct {
+	AndOr      *AndOr
+	Background bool
+}
+
+// AndOr is a chain of pipelines joined by && and ||. Ops[i] is the operator
+// between Pipelines[i] and Pipelines[i+1].
+type AndOr struct {
+	Pipelines []*Pipeline
+	Ops       []string
+}
+
+// Pipeline is a sequence of commands connected by pipes. Negated is set when
+// the pipeline was prefixed with !.
+type Pipeline struct {
+	Negated  bool
+	Commands []Command
+}
+
+// Command is implemented by every node that can appear as a pipeline stage
+type Command interface {
+	commandNode()
+}
+
+// SimpleCommand is a command name with its arguments and redirections.
+// Assigns holds the NAME=value words preceding the command name, which set
+// shell variables when there is no command and the environment of the
+// command otherwise.
+type SimpleCommand struct {
+	Assigns   []*Word
+	Args      []*Word
+	Redirects []*Redirect
+}
+
+// Subshell is a list grouped with ( ... )
+type Subshell struct {
+	Body      *List
+	Redirects []*Redirect
+}
+
+// BraceGroup is a list grouped with { ...; }
+type BraceGroup struct {
+	Body      *List
+	Redirects []*Redirect
+}
+
+// IfClause is an if command. Bodies[i] runs when Conds[i] succeeds, where
+// the conditions after the first come from elif branches. Else, if set,
+// runs when none of them succeeded.
+type IfClause struct {
+	Conds     []*List
+	Bodies    []*List
+	Else      *List
+	Redirects []*Redirect
+}
+
+// WhileClause is a while or until loop. Until is set for until loops, which
+// run the body as long as the condition fails.
+type WhileClause struct {
+	Cond      *List
+	Body      *List
+	Until     bool
+	Redirects []*Redirect
+}
+
+// ForClause is a for loop over a list of words. In is false when the list
+// was left out, in which case the loop iterates over the positional
+// parameters.
+type ForClause struct {
+	Name      string
+	In        bool
+	Words     []*Word
+	Body      *List
+	Redirects []*Redirect
+}
+
+// ArithForClause is a C-style for ((init; cond; step)) loop. An empty
+// condition is true.
+type ArithForClause struct {
+	Init      *Word
+	Cond      *Word
+	Step      *Word
+	Body      *List
+	Redirects []*Redirect
+}
+
+// CaseClause is a case command. The body of the first item with a pattern
+// matching the word runs.
+type CaseClause struct {
+	Word      *Word
+	Items     []*CaseItem
+	Redirects []*Redirect
+}
+
+// CaseItem is one pattern list of a case command with its body. Terminator
+// is ;; to end the command, ;& to fall through to the next body, or ;;& to
+// go on testing the following patterns.
+type CaseItem struct {
+	Patterns   []*Word
+	Body       *List
+	Terminator string
+}
+
+// FunctionDef defines a function, name() compound-command or function name
+// compound-command. Redirections written after the body are part of Body and
+// apply to every call.
+type FunctionDef struct {
+	Name string
+	Body Command
+}
+
+// ArithCommand is an arithmetic command, ((expression)), which succeeds when
+// the expression evaluates to a non-zero value
+type ArithCommand struct {
+	Expr      *Word
+	Redirects []*Redirect
+}
+
+func (*SimpleCommand) commandNode()  {}
+func (*Subshell) commandNode()       {}
+func (*BraceGroup) commandNode()     {}
+func (*ArithCommand) commandNode()   {}
+func (*IfClause) commandNode()       {}
+func (*WhileClause) commandNode()    {}
+func (*ForClause) commandNode()      {}
+func (*ArithForClause) commandNode() {}
+func (*CaseClause) commandNode()     {}
+func (*FunctionDef) commandNode()    {}
+
+// Redirect is a single redirection. Fd is the file descriptor written before
+// the operator, or -1 when the operator's default applies. For the
+// here-document operators << and <<- Target is the delimiter and Body the
+// text read from the following lines.
+type Redirect struct {
+	Fd     int
+	Op     string
+	Target *Word
+	Body   *Word
+}
+
+// Word is a shell word made of unquoted and quoted parts
+type Word struct {
+	Parts []WordPart
+}
+
+// WordPart is implemented by every node that can make up a Word
+type WordPart interface {
+	wordPart()
+}
+
+// Literal is unquoted text
+type Literal struct {
+	Value string
+}
+
+// Escaped is a single character preceded by a backslash outside quotes
+type Escaped struct {
+	Value string
+}
+
+// SingleQuoted is the content of a '...' string, taken literally
+type SingleQuoted struct {
+	Value string
+}
+
+// DoubleQuoted is the content of a "..." string
+type DoubleQuoted struct {
+	Parts []WordPart
+}
+
+// ParamExp is a parameter expansion, either $name or ${name} optionally
+// followed by an operator and its argument, e.g. ${name:-default}. Short is
+// set for the $name form and Length for ${#name}. Index is the subscript of
+// an array element, ${name[index]}. Arg2 holds the replacement of
+// ${name/pattern/string} and the length of ${name:offset:length}.
+type ParamExp struct {
+	Name   string
+	Short  bool
+	Length bool
+	Index  *Word
+	Op     string
+	Arg    *Word
+	Arg2   *Word
+}
+
+// CmdSubst is a command substitution, $(...) or `...`, whose output
+// replaces it in the word
+type CmdSubst struct {
+	Body      *List
+	Backquote bool
+}
+
+// ProcSubst is a process substitution, <(...) or >(...), replaced by a
+// /dev/fd path connected to the command through a pipe. Write is set for
+// >(...), whose path is written to and feeds the command's input.
+type ProcSubst struct {
+	Body  *List
+	Write bool
+}
+
+// ArithExp is an arithmetic expansion, $((...)). The expression undergoes
+// parameter expansion and command substitution before it is evaluated.
+type ArithExp struct {
+	Expr *Word
+}
+
+func (*Literal) wordPart()      {}
+func (*Escaped) wordPart()      {}
+func (*SingleQuoted) wordPart() {}
+func (*DoubleQuoted) wordPart() {}
+func (*ParamExp) wordPart()     {}
+func (*CmdSubst) wordPart()     {}
+func (*ArithExp) wordPart()     {}
+func (*ProcSubst) wordPart()    {}
+
+// Lit returns the literal value of the word if it is made of a single
+// unquoted part, which is how reserved words and operators are recognised
+func (w *Word) Lit() (string, bool) {
+	if len(w.Parts) != 1 {
+		return "", false
+	}
+	lit, ok := w.Parts[0].(*Literal)
+	if !ok {
+		return "", false
+	}
+	return lit.Value, true
+}
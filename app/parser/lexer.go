@@ -0,0 +1,775 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TokenKind identifies the type of a lexical token
+type TokenKind int
+
+const (
+	EOF TokenKind = iota
+	WORD
+	OPERATOR
+	IO_NUMBER
+	NEWLINE
+)
+
+// Token is a single lexical token. Word is set for WORD tokens and Value holds
+// the operator text or the digits of an IO_NUMBER.
+type Token struct {
+	Kind  TokenKind
+	Value string
+	Word  *Word
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case EOF:
+		return "EOF"
+	case NEWLINE:
+		return "newline"
+	case WORD:
+		return wordString(t.Word)
+	}
+	return t.Value
+}
+
+// operators lists every control and redirection operator, longest first so
+// that the lexer always matches the longest possible operator
+var operators = []string{
+	"&>>", "&>|", "<<-", "<<<", ";;&",
+	"&&", "||", ">>", ">|", "&>", ">&", "<<", "<&", "<>", ";;", ";&",
+	"|", "&", ";", "(", ")", ">", "<",
+}
+
+// ErrIncomplete is returned when the input ends before a command is complete,
+// such as inside an if command or before the delimiter of a here-document.
+// An interactive shell can read more lines and parse again.
+var ErrIncomplete = errors.New("unexpected end of file")
+
+// incompleteError reports input that ends inside a quote, an expansion or a
+// command substitution. It keeps its own message but matches ErrIncomplete.
+type incompleteError struct {
+	match string // the text that would close the construct
+}
+
+func (e *incompleteError) Error() string {
+	return fmt.Sprintf("unexpected EOF while looking for matching `%s'", e.match)
+}
+
+func (e *incompleteError) Is(target error) bool {
+	return target == ErrIncomplete
+}
+
+// Lexer splits shell input into tokens
+type Lexer struct {
+	input string
+	pos   int
+	depth int // parentheses opened inside an arithmetic expression
+
+	noComments bool // # at the start of a word is taken literally
+
+	heredocs []*Redirect // here-documents whose body starts on the next line
+}
+
+// NewLexer creates a lexer for the given input
+func NewLexer(input string) *Lexer {
+	return &Lexer{input: input}
+}
+
+// Next returns the next token of the input
+func (l *Lexer) Next() (Token, error) {
+	// Skip blanks between tokens, including escaped newlines
+	for l.pos < len(l.input) {
+		if l.input[l.pos] == ' ' || l.input[l.pos] == '\t' {
+			l.pos++
+		} else if strings.HasPrefix(l.input[l.pos:], "\\\n") {
+			l.pos += 2
+		} else {
+			break
+		}
+	}
+
+	if l.pos >= len(l.input) {
+		if len(l.heredocs) > 0 {
+			return Token{}, l.incompleteHeredoc()
+		}
+		return Token{Kind: EOF}, nil
+	}
+
+	// A # starting a word comments out the rest of the line
+	if l.input[l.pos] == '#' && !l.noComments {
+		end := strings.IndexByte(l.input[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.input) - l.pos
+		}
+		l.pos += end
+		return l.Next()
+	}
+
+	if l.input[l.pos] == '\n' {
+		l.pos++
+		if err := l.readHeredocs(); err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: NEWLINE, Value: "\n"}, nil
+	}
+
+	if op := l.operator(); op != "" && !l.atProcSubst() {
+		l.pos += len(op)
+		return Token{Kind: OPERATOR, Value: op}, nil
+	}
+
+	// A number directly followed by a redirection operator names a descriptor
+	if digits := l.ioNumber(); digits != "" {
+		l.pos += len(digits)
+		return Token{Kind: IO_NUMBER, Value: digits}, nil
+	}
+
+	word, err := l.readWord()
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Kind: WORD, Word: word}, nil
+}
+
+// operator returns the operator at the current position, if any
+func (l *Lexer) operator() string {
+	for _, op := range operators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// atProcSubst reports whether a process substitution, <( or >(, starts at
+// the current position
+func (l *Lexer) atProcSubst() bool {
+	rest := l.input[l.pos:]
+	return strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">(")
+}
+
+// ioNumber returns the digits at the current position if they are directly
+// followed by a redirection operator
+func (l *Lexer) ioNumber() string {
+	end := l.pos
+	for end < len(l.input) && l.input[end] >= '0' && l.input[end] <= '9' {
+		end++
+	}
+	if end == l.pos || end >= len(l.input) || (l.input[end] != '>' && l.input[end] != '<') {
+		return ""
+	}
+	return l.input[l.pos:end]
+}
+
+// isWordEnd reports whether c ends an unquoted word
+func isWordEnd(c byte) bool {
+	return strings.IndexByte(" \t\n|&;()<>", c) >= 0
+}
+
+// wordContext describes where word parts are being read. It decides which
+// character ends them and how quotes and backslashes behave.
+type wordContext int
+
+const (
+	ctxWord           wordContext = iota // an unquoted shell word
+	ctxDouble                            // the inside of "..."
+	ctxParamArg                          // the argument of ${name<op>arg}
+	ctxDoubleParamArg                    // the argument of ${name<op>arg} inside "..."
+	ctxPattern                           // the pattern of ${name#pattern} and similar
+	ctxSubstPattern                      // the pattern of ${name/pattern/string}
+	ctxOffset                            // the offset of ${name:offset:length}
+	ctxArith                             // the expression of $((...)) and ((...))
+	ctxSubscript                         // the index of ${name[index]}
+	ctxHeredoc                           // the body of a here-document
+)
+
+// quoted reports whether text read in the context is inside double quotes
+func (ctx wordContext) quoted() bool {
+	return ctx == ctxDouble || ctx == ctxDoubleParamArg || ctx == ctxArith || ctx == ctxSubscript || ctx == ctxHeredoc
+}
+
+// atEnd reports whether c terminates parts read in the context
+func (ctx wordContext) atEnd(c byte) bool {
+	switch ctx {
+	case ctxWord:
+		return isWordEnd(c)
+	case ctxDouble:
+		return c == '"'
+	case ctxSubstPattern:
+		return c == '/' || c == '}'
+	case ctxOffset:
+		return c == ':' || c == '}'
+	case ctxArith:
+		return c == ')'
+	case ctxSubscript:
+		return c == ']'
+	case ctxHeredoc:
+		return false
+	}
+	return c == '}'
+}
+
+// readWord reads a word made of unquoted, escaped and quoted parts
+func (l *Lexer) readWord() (*Word, error) {
+	parts, err := l.readParts(ctxWord)
+	if err != nil {
+		return nil, err
+	}
+	return &Word{Parts: parts}, nil
+}
+
+// readParts reads word parts until the character ending the context. The
+// terminator itself is left unread.
+func (l *Lexer) readParts(ctx wordContext) ([]WordPart, error) {
+	var parts []WordPart
+	var literal strings.Builder
+
+	// flush adds any pending literal text to the parts
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, &Literal{Value: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for {
+		if l.pos >= len(l.input) {
+			if ctx == ctxWord || ctx == ctxHeredoc {
+				break
+			}
+			return nil, l.unterminated(ctx)
+		}
+
+		c := l.input[l.pos]
+		if ctx == ctxWord && l.atProcSubst() {
+			flush()
+			write := c == '>'
+			l.pos++
+			body, err := l.readSubstBody()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, &ProcSubst{Body: body, Write: write})
+			continue
+		}
+		if ctx == ctxArith && c == ')' && l.depth > 0 {
+			// Closes a parenthesis opened inside the expression
+			l.depth--
+			literal.WriteByte(c)
+			l.pos++
+			continue
+		}
+		if ctx.atEnd(c) {
+			break
+		}
+		if ctx == ctxArith && c == '(' {
+			l.depth++
+		}
+
+		switch {
+		case c == '\\' && l.pos+1 == len(l.input) && ctx != ctxHeredoc:
+			// A backslash ending the input continues the line on the next one
+			return nil, fmt.Errorf("syntax error: %w", ErrIncomplete)
+		case c == '\\' && l.pos+1 < len(l.input):
+			next := l.input[l.pos+1]
+			l.pos += 2
+			if next == '\n' {
+				// Line continuation: drop both characters
+				continue
+			}
+			if !ctx.quoted() {
+				// Preserve the literal value of the next character, including space
+				flush()
+				parts = append(parts, &Escaped{Value: string(next)})
+				continue
+			}
+			// In double quotes, backslash only escapes $, `, ", \ and newline
+			// (and the closing brace of a parameter expansion). A here-document
+			// keeps the backslash before ".
+			if strings.IndexByte("$`\\", next) >= 0 || (next == '"' && ctx != ctxHeredoc) || (next == '}' && ctx == ctxDoubleParamArg) {
+				literal.WriteByte(next)
+			} else {
+				// For all other characters, keep both the backslash and the character
+				literal.WriteByte('\\')
+				literal.WriteByte(next)
+			}
+		case c == '\'' && !ctx.quoted():
+			flush()
+			end := strings.IndexByte(l.input[l.pos+1:], '\'')
+			if end < 0 {
+				return nil, &incompleteError{match: "'"}
+			}
+			// Single quotes: no escaping allowed - everything is literal
+			parts = append(parts, &SingleQuoted{Value: l.input[l.pos+1 : l.pos+1+end]})
+			l.pos += end + 2
+		case c == '"' && ctx != ctxDouble && ctx != ctxHeredoc:
+			flush()
+			l.pos++
+			inner, err := l.readParts(ctxDouble)
+			if err != nil {
+				return nil, err
+			}
+			l.pos++ // closing quote
+			parts = append(parts, &DoubleQuoted{Parts: inner})
+		case c == '`':
+			flush()
+			part, err := l.readBackquote(ctx)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part)
+		case c == '$':
+			part, err := l.readDollar(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if part == nil {
+				// A lone $ is taken literally
+				literal.WriteByte('$')
+				l.pos++
+				continue
+			}
+			flush()
+			parts = append(parts, part)
+		default:
+			literal.WriteByte(c)
+			l.pos++
+		}
+	}
+	flush()
+
+	return parts, nil
+}
+
+// unterminated returns the error for input ending inside the context
+func (l *Lexer) unterminated(ctx wordContext) error {
+	switch ctx {
+	case ctxDouble:
+		return &incompleteError{match: `"`}
+	case ctxArith:
+		return &incompleteError{match: ")"}
+	}
+	return &incompleteError{match: "}"}
+}
+
+// readDollar reads the expansion introduced by the $ at the current
+// position. It returns nil if the $ does not start an expansion.
+func (l *Lexer) readDollar(ctx wordContext) (WordPart, error) {
+	rest := l.input[l.pos+1:]
+	if strings.HasPrefix(rest, "{") {
+		return l.readParamExp(ctx)
+	}
+	if strings.HasPrefix(rest, "((") {
+		start := l.pos
+		l.pos += 3
+		if expr, ok := l.readArith(); ok {
+			return &ArithExp{Expr: expr}, nil
+		}
+		// Not closed by )), so this is a command substitution starting with
+		// a subshell, e.g. $( (cd dir; pwd) )
+		l.pos = start
+	}
+	if strings.HasPrefix(rest, "(") {
+		return l.readCmdSubst()
+	}
+	if name := readParamName(rest, false); name != "" {
+		l.pos += 1 + len(name)
+		return &ParamExp{Name: name, Short: true}, nil
+	}
+	return nil, nil
+}
+
+// readHeredocs reads the bodies of the here-documents started on the line
+// that just ended. Each body runs up to a line holding only its delimiter;
+// with <<- leading tabs are removed from the lines and the delimiter line.
+func (l *Lexer) readHeredocs() error {
+	for len(l.heredocs) > 0 {
+		redirect := l.heredocs[0]
+		delimiter, quoted := heredocDelimiter(redirect.Target)
+
+		var body strings.Builder
+		for {
+			if l.pos >= len(l.input) {
+				return l.incompleteHeredoc()
+			}
+			end := strings.IndexByte(l.input[l.pos:], '\n')
+			line := l.input[l.pos:]
+			if end >= 0 {
+				line = l.input[l.pos : l.pos+end]
+				l.pos += end + 1
+			} else {
+				l.pos = len(l.input)
+			}
+
+			if redirect.Op == "<<-" {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == delimiter {
+				break
+			}
+			body.WriteString(line + "\n")
+		}
+
+		// A quoted delimiter leaves the body as it is, otherwise it is
+		// expanded like text in double quotes
+		if quoted {
+			redirect.Body = &Word{Parts: []WordPart{&SingleQuoted{Value: body.String()}}}
+		} else {
+			parts, err := NewLexer(body.String()).readParts(ctxHeredoc)
+			if err != nil {
+				return err
+			}
+			redirect.Body = &Word{Parts: parts}
+		}
+		l.heredocs = l.heredocs[1:]
+	}
+	return nil
+}
+
+// incompleteHeredoc returns the error for input ending inside a
+// here-document
+func (l *Lexer) incompleteHeredoc() error {
+	delimiter, _ := heredocDelimiter(l.heredocs[0].Target)
+	return fmt.Errorf("%w: wanted here-document delimiter `%s'", ErrIncomplete, delimiter)
+}
+
+// heredocDelimiter returns the delimiter of a here-document with its quotes
+// removed, and whether any part of it was quoted
+func heredocDelimiter(word *Word) (string, bool) {
+	var sb strings.Builder
+	quoted := false
+	var write func(parts []WordPart)
+	write = func(parts []WordPart) {
+		for _, part := range parts {
+			switch p := part.(type) {
+			case *Literal:
+				sb.WriteString(p.Value)
+			case *Escaped:
+				sb.WriteString(p.Value)
+				quoted = true
+			case *SingleQuoted:
+				sb.WriteString(p.Value)
+				quoted = true
+			case *DoubleQuoted:
+				write(p.Parts)
+				quoted = true
+			default:
+				// Expansions are not performed on the delimiter
+				writePart(&sb, part)
+			}
+		}
+	}
+	write(word.Parts)
+	return sb.String(), quoted
+}
+
+// readArith reads an arithmetic expression up to the closing )), with the
+// position just after the opening parentheses. It reports false if the
+// expression is not closed by )), leaving the position to the caller.
+func (l *Lexer) readArith() (*Word, bool) {
+	depth := l.depth
+	defer func() { l.depth = depth }()
+
+	l.depth = 0
+	parts, err := l.readParts(ctxArith)
+	if err != nil || !strings.HasPrefix(l.input[l.pos:], "))") {
+		return nil, false
+	}
+	l.pos += 2
+	return &Word{Parts: parts}, true
+}
+
+// readCmdSubst reads a $(...) command substitution starting at the $
+func (l *Lexer) readCmdSubst() (*CmdSubst, error) {
+	l.pos++ // $
+	body, err := l.readSubstBody()
+	if err != nil {
+		return nil, err
+	}
+	return &CmdSubst{Body: body}, nil
+}
+
+// readSubstBody reads the command list of a substitution starting at its
+// opening parenthesis. The body is parsed by a nested parser sharing this
+// lexer, so that it ends at the parenthesis that really closes it.
+func (l *Lexer) readSubstBody() (*List, error) {
+	l.pos++ // (
+
+	p := &Parser{lexer: l}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator(")") {
+		if p.tok.Kind == EOF {
+			return nil, &incompleteError{match: ")"}
+		}
+		return nil, p.unexpected()
+	}
+	return body, nil
+}
+
+// readBackquote reads a `...` command substitution. Inside it a backslash
+// only escapes $, ` and \ (and " within double quotes); the unescaped text
+// is then parsed as a command list of its own.
+func (l *Lexer) readBackquote(ctx wordContext) (*CmdSubst, error) {
+	l.pos++ // opening backquote
+
+	var source strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return nil, &incompleteError{match: "`"}
+		}
+		c := l.input[l.pos]
+		if c == '`' {
+			l.pos++
+			break
+		}
+		if c == '\\' && l.pos+1 < len(l.input) {
+			next := l.input[l.pos+1]
+			if next == '$' || next == '`' || next == '\\' || (next == '"' && ctx.quoted()) {
+				source.WriteByte(next)
+				l.pos += 2
+				continue
+			}
+		}
+		source.WriteByte(c)
+		l.pos++
+	}
+
+	nested := NewLexer(source.String())
+	nested.noComments = l.noComments
+	body, err := parse(nested)
+	if err != nil {
+		return nil, err
+	}
+	return &CmdSubst{Body: body, Backquote: true}, nil
+}
+
+// paramOperators lists the operators allowed after the name in ${...},
+// longest first so that ## is not taken for #
+var paramOperators = []string{
+	":-", ":=", ":?", ":+",
+	"##", "%%", "//", "/#", "/%", "^^", ",,",
+	"#", "%", "/", "^", ",", ":",
+	"-", "=", "?", "+",
+}
+
+// readParamExp reads a ${...} expansion starting at the $
+func (l *Lexer) readParamExp(ctx wordContext) (*ParamExp, error) {
+	start := l.pos
+	l.pos += 2 // ${
+
+	exp := &ParamExp{}
+	rest := l.input[l.pos:]
+	if strings.HasPrefix(rest, "#") && !strings.HasPrefix(rest, "#}") && readParamName(rest[1:], true) != "" {
+		// ${#name} is the length of the value, while ${#} is the number of
+		// positional parameters
+		exp.Length = true
+		l.pos++
+	}
+
+	exp.Name = readParamName(l.input[l.pos:], true)
+	l.pos += len(exp.Name)
+	if exp.Name == "" {
+		return nil, l.badSubstitution(start)
+	}
+
+	// An array element, or all of them with ${name[@]} and ${name[*]}
+	if l.pos < len(l.input) && l.input[l.pos] == '[' && readName(exp.Name) != "" {
+		l.pos++
+		var err error
+		if exp.Index, err = l.readParamWord(ctxSubscript); err != nil {
+			return nil, err
+		}
+		if l.input[l.pos-1] != ']' {
+			return nil, l.badSubstitution(start)
+		}
+	}
+
+	if l.pos < len(l.input) && l.input[l.pos] == '}' {
+		l.pos++
+		return exp, nil
+	}
+	if exp.Length {
+		return nil, l.badSubstitution(start)
+	}
+
+	for _, op := range paramOperators {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			exp.Op = op
+			break
+		}
+	}
+	if exp.Op == "" {
+		return nil, l.badSubstitution(start)
+	}
+	l.pos += len(exp.Op)
+
+	// Patterns are active even inside double quotes, while the words used
+	// as values keep the quoting of their surroundings
+	valueCtx := ctxParamArg
+	if ctx.quoted() {
+		valueCtx = ctxDoubleParamArg
+	}
+
+	var err error
+	switch exp.Op {
+	case "#", "##", "%", "%%", "^", "^^", ",", ",,":
+		exp.Arg, err = l.readParamWord(ctxPattern)
+	case "/", "//", "/#", "/%":
+		exp.Arg, err = l.readParamWord(ctxSubstPattern)
+		if err == nil && l.input[l.pos-1] == '/' {
+			exp.Arg2, err = l.readParamWord(valueCtx)
+		}
+	case ":":
+		exp.Arg, err = l.readParamWord(ctxOffset)
+		if err == nil && l.input[l.pos-1] == ':' {
+			exp.Arg2, err = l.readParamWord(ctxParamArg)
+		}
+	default:
+		exp.Arg, err = l.readParamWord(valueCtx)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the last word consumed the closing brace
+	if l.input[l.pos-1] != '}' {
+		return nil, l.badSubstitution(start)
+	}
+	return exp, nil
+}
+
+// readParamWord reads one word of a ${...} expansion along with the
+// character that ends it
+func (l *Lexer) readParamWord(ctx wordContext) (*Word, error) {
+	parts, err := l.readParts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.pos++ // terminator
+
+	// A word inside double quotes is quoted as well
+	if ctx == ctxDoubleParamArg {
+		parts = []WordPart{&DoubleQuoted{Parts: parts}}
+	}
+	return &Word{Parts: parts}, nil
+}
+
+// badSubstitution returns the error for a malformed ${...} starting at start
+func (l *Lexer) badSubstitution(start int) error {
+	end := strings.IndexByte(l.input[start:], '}')
+	if end < 0 {
+		return &incompleteError{match: "}"}
+	}
+	return fmt.Errorf("%s: bad substitution", l.input[start:start+end+1])
+}
+
+// readParamName returns the parameter at the start of s: a variable name, a
+// positional parameter or one of the special parameters. Without braces
+// positional parameters are limited to a single digit.
+func readParamName(s string, braced bool) string {
+	if name := readName(s); name != "" {
+		return name
+	}
+	if s == "" {
+		return ""
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		if !braced {
+			return s[:1]
+		}
+		i := 1
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return s[:i]
+	}
+	if strings.IndexByte("?$!#@*-", s[0]) >= 0 {
+		return s[:1]
+	}
+	return ""
+}
+
+// readName returns the variable name at the start of s, if any
+func readName(s string) string {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			i++
+			continue
+		}
+		break
+	}
+	return s[:i]
+}
+
+// wordString renders a word back to shell source, used in error messages
+func wordString(w *Word) string {
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		writePart(&sb, part)
+	}
+	return sb.String()
+}
+
+func writePart(sb *strings.Builder, part WordPart) {
+	switch p := part.(type) {
+	case *Literal:
+		sb.WriteString(p.Value)
+	case *Escaped:
+		sb.WriteString("\\" + p.Value)
+	case *SingleQuoted:
+		sb.WriteString("'" + p.Value + "'")
+	case *DoubleQuoted:
+		sb.WriteByte('"')
+		for _, inner := range p.Parts {
+			writePart(sb, inner)
+		}
+		sb.WriteByte('"')
+	case *ParamExp:
+		if p.Short {
+			sb.WriteString("$" + p.Name)
+			return
+		}
+		sb.WriteString("${")
+		if p.Length {
+			sb.WriteByte('#')
+		}
+		sb.WriteString(p.Name + p.Op)
+		if p.Arg != nil {
+			sb.WriteString(wordString(p.Arg))
+		}
+		if p.Arg2 != nil {
+			sb.WriteString(p.Op[:1] + wordString(p.Arg2))
+		}
+		sb.WriteByte('}')
+	case *ArithExp:
+		sb.WriteString("$((" + wordString(p.Expr) + "))")
+	case *ProcSubst:
+		if p.Write {
+			sb.WriteString(">(...)")
+		} else {
+			sb.WriteString("<(...)")
+		}
+	case *CmdSubst:
+		if p.Backquote {
+			sb.WriteString("`...`")
+		} else {
+			sb.WriteString("$(...)")
+		}
+	}
+}
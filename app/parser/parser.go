@@ -1,108 +1,817 @@
 package parser
 
 import (
-	"os"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+)
+
+// Control operators separating the commands of a list
+const (
+	PIPE       = "|"
+	AND        = "&&"
+	OR         = "||"
+	SEMICOLON  = ";"
+	BACKGROUND = "&"
+)
 
-	"github.com/codecrafters-io/shell-starter-go/app/utils"
+// Terminators of the items of a case command
+const (
+	CASE_BREAK       = ";;"
+	CASE_FALLTHROUGH = ";&"
+	CASE_CONTINUE    = ";;&"
 )
 
-func ParseInput(input string) ([]string, *os.File, *os.File, error) {
-	// Trim any carriage returns or newlines
-	_input := strings.Trim(input, "\r\n")
+// Parser is a recursive-descent parser turning tokens into an AST
+type Parser struct {
+	lexer *Lexer
+	tok   Token
+}
+
+// Parse parses a complete input into a command list
+func Parse(input string) (*List, error) {
+	return parse(NewLexer(input))
+}
+
+// ParseNoComments parses input in which # does not start a comment, as in
+// an interactive shell with the interactive_comments option turned off
+func ParseNoComments(input string) (*List, error) {
+	l := NewLexer(input)
+	l.noComments = true
+	return parse(l)
+}
+
+func parse(l *Lexer) (*List, error) {
+	p := &Parser{lexer: l}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != EOF {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// next advances to the next token
+func (p *Parser) next() error {
+	tok, err := p.lexer.Next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *Parser) isOperator(op string) bool {
+	return p.tok.Kind == OPERATOR && p.tok.Value == op
+}
+
+// isReserved reports whether the current token is the given reserved word.
+// Reserved words are only recognised when they are written unquoted.
+func (p *Parser) isReserved(word string) bool {
+	if p.tok.Kind != WORD {
+		return false
+	}
+	lit, ok := p.tok.Word.Lit()
+	return ok && lit == word
+}
+
+func (p *Parser) skipNewlines() error {
+	for p.tok.Kind == NEWLINE {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Parser) unexpected() error {
+	if p.tok.Kind == EOF {
+		return fmt.Errorf("syntax error: %w", ErrIncomplete)
+	}
+	return fmt.Errorf("syntax error near unexpected token `%s'", p.tok)
+}
+
+// listTerminators are the reserved words that end the list inside a
+// compound command
+var listTerminators = []string{"}", "then", "elif", "else", "fi", "do", "done", "esac"}
+
+// atListEnd reports whether the current token ends a list: end of input, a
+// closing parenthesis or a reserved word closing a compound command
+func (p *Parser) atListEnd() bool {
+	if p.tok.Kind == EOF || p.isOperator(")") || p.isCaseTerminator() {
+		return true
+	}
+	for _, word := range listTerminators {
+		if p.isReserved(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCaseTerminator reports whether the current token ends an item of a
+// case command
+func (p *Parser) isCaseTerminator() bool {
+	return p.isOperator(CASE_BREAK) || p.isOperator(CASE_FALLTHROUGH) || p.isOperator(CASE_CONTINUE)
+}
+
+// expect consumes the given reserved word or fails with a syntax error
+func (p *Parser) expect(word string) error {
+	if !p.isReserved(word) {
+		return p.unexpected()
+	}
+	return p.next()
+}
+
+// parseList parses and-or lists separated by ;, & or newlines until the end
+// of the input or a token closing the enclosing compound command
+func (p *Parser) parseList() (*List, error) {
+	list := &List{}
+
+	for !p.atListEnd() {
+		andOr, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		item := &ListItem{AndOr: andOr}
+		list.Items = append(list.Items, item)
+
+		switch {
+		case p.isOperator(SEMICOLON), p.isOperator(BACKGROUND):
+			item.Background = p.tok.Value == BACKGROUND
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		case p.tok.Kind == NEWLINE:
+		default:
+			if !p.atListEnd() {
+				return nil, p.unexpected()
+			}
+		}
+
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+// parseAndOr parses pipelines joined by && and ||
+func (p *Parser) parseAndOr() (*AndOr, error) {
+	andOr := &AndOr{}
+
+	for {
+		pipeline, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+
+		if !p.isOperator(AND) && !p.isOperator(OR) {
+			return andOr, nil
+		}
+		andOr.Ops = append(andOr.Ops, p.tok.Value)
+
+		// The next pipeline may start on a following line
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parsePipeline parses commands connected by |, optionally negated with !
+func (p *Parser) parsePipeline() (*Pipeline, error) {
+	pipeline := &Pipeline{}
 
-	if _input == "" {
-		return []string{}, nil, nil, nil
+	if p.isReserved("!") {
+		pipeline.Negated = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Commands = append(pipeline.Commands, cmd)
+
+		if !p.isOperator(PIPE) {
+			return pipeline, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCommand parses a simple command or a compound command with its
+// trailing redirections
+func (p *Parser) parseCommand() (Command, error) {
+	switch {
+	case p.isOperator("(") && strings.HasPrefix(p.lexer.input[p.lexer.pos:], "("):
+		// (( starts an arithmetic command unless it turns out to be nested
+		// subshells, e.g. ((cd dir; pwd))
+		start := p.lexer.pos
+		p.lexer.pos++
+		if expr, ok := p.lexer.readArith(); ok {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			redirects, err := p.parseRedirects()
+			if err != nil {
+				return nil, err
+			}
+			return &ArithCommand{Expr: expr, Redirects: redirects}, nil
+		}
+		p.lexer.pos = start
+		return p.parseSubshell()
+	case p.isOperator("("):
+		return p.parseSubshell()
+	case p.isReserved("{"):
+		body, err := p.parseGroup("}")
+		if err != nil {
+			return nil, err
+		}
+		redirects, err := p.parseRedirects()
+		if err != nil {
+			return nil, err
+		}
+		return &BraceGroup{Body: body, Redirects: redirects}, nil
+	case p.isReserved("if"):
+		return p.parseIf()
+	case p.isReserved("while"), p.isReserved("until"):
+		return p.parseWhile()
+	case p.isReserved("for"):
+		return p.parseFor()
+	case p.isReserved("case"):
+		return p.parseCase()
+	case p.isReserved("function"):
+		return p.parseFunction(true)
+	case p.isFunctionName():
+		return p.parseFunction(false)
+	}
+
+	return p.parseSimpleCommand()
+}
+
+// parseSubshell parses a ( ... ) subshell and its redirections
+func (p *Parser) parseSubshell() (*Subshell, error) {
+	body, err := p.parseGroup(")")
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	return &Subshell{Body: body, Redirects: redirects}, nil
+}
+
+// parseIf parses if ... then ... [elif ... then ...] [else ...] fi
+func (p *Parser) parseIf() (*IfClause, error) {
+	clause := &IfClause{}
+
+	for {
+		// The current token is if or elif
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		cond, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		clause.Conds = append(clause.Conds, cond)
+		clause.Bodies = append(clause.Bodies, body)
+
+		if !p.isReserved("elif") {
+			break
+		}
 	}
 
-	var tokens []string
-	var currentToken string
-	var inWord bool = false
+	if p.isReserved("else") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		body, err := p.parseCompoundList()
+		if err != nil {
+			return nil, err
+		}
+		clause.Else = body
+	}
+
+	if err := p.expect("fi"); err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	clause.Redirects = redirects
+	return clause, nil
+}
+
+// parseWhile parses while ... do ... done and until ... do ... done
+func (p *Parser) parseWhile() (*WhileClause, error) {
+	clause := &WhileClause{Until: p.isReserved("until")}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	cond, err := p.parseCompoundList()
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
 
-	// Process the input until we've consumed it all
-	for len(_input) > 0 {
-		// Skip leading whitespace when not in a word
-		if !inWord {
-			_input = strings.TrimLeft(_input, " \t")
-			if len(_input) == 0 {
-				break
+	clause.Cond, clause.Body, clause.Redirects = cond, body, redirects
+	return clause, nil
+}
+
+// parseFor parses for name [in words]; do ... done and the arithmetic form
+// for ((init; cond; step)); do ... done
+func (p *Parser) parseFor() (Command, error) {
+	// Look at the raw input, as (( is not a token of its own
+	rest := p.lexer.input[p.lexer.pos:]
+	if trimmed := strings.TrimLeft(rest, " \t"); strings.HasPrefix(trimmed, "((") {
+		p.lexer.pos += len(rest) - len(trimmed) + 2
+		return p.parseArithFor()
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != WORD {
+		return nil, p.unexpected()
+	}
+	name, ok := p.tok.Word.Lit()
+	if !ok || readName(name) != name {
+		return nil, fmt.Errorf("`%s': not a valid identifier", p.tok)
+	}
+	clause := &ForClause{Name: name}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	if p.isReserved("in") {
+		clause.In = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for p.tok.Kind == WORD {
+			clause.Words = append(clause.Words, p.tok.Word)
+			if err := p.next(); err != nil {
+				return nil, err
 			}
 		}
+	}
+	if err := p.endLoopHead(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	clause.Body, clause.Redirects = body, redirects
+	return clause, nil
+}
+
+// parseArithFor parses an arithmetic for loop, with the position just after
+// the opening ((
+func (p *Parser) parseArithFor() (*ArithForClause, error) {
+	expr, ok := p.lexer.readArith()
+	if !ok {
+		return nil, errors.New("syntax error: `((' not closed by `))' in arithmetic for")
+	}
+	exprs := splitWord(expr, ';')
+	if len(exprs) != 3 {
+		return nil, fmt.Errorf("syntax error: arithmetic expression required: `((%s))'", wordString(expr))
+	}
+	clause := &ArithForClause{Init: exprs[0], Cond: exprs[1], Step: exprs[2]}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.endLoopHead(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseDoGroup()
+	if err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	clause.Body, clause.Redirects = body, redirects
+	return clause, nil
+}
+
+// endLoopHead skips the ; or newlines ending the head of a for loop
+func (p *Parser) endLoopHead() error {
+	if p.isOperator(SEMICOLON) {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+	return p.skipNewlines()
+}
 
-		// Check if we start with a quote
-		if _input[0] == '"' || _input[0] == '\'' {
-			inWord = true
-			// Get the quote character
-			quote := _input[0]
-			// Remove the opening quote
-			_input = _input[1:]
-
-			// Find the matching closing quote, handling escaped quotes
-			for len(_input) > 0 {
-				// Single quotes: no escaping allowed - everything is literal
-				if quote == '\'' {
-					if _input[0] == '\'' {
-						// End of single quoted section
-						_input = _input[1:]
-						break
-					} else {
-						// Add the character to our token as is
-						currentToken += string(_input[0])
-						_input = _input[1:]
-					}
-				} else { // Double quotes: only backslash followed by certain chars are escaped
-					if _input[0] == '\\' && len(_input) > 1 {
-						// In double quotes, backslash only escapes $, `, ", \ and newline
-						if _input[1] == '$' || _input[1] == '`' || _input[1] == '"' ||
-							_input[1] == '\\' || _input[1] == '\n' {
-							currentToken += string(_input[1])
-						} else {
-							// For all other characters, keep both the backslash and the character
-							currentToken += string('\\') + string(_input[1])
-						}
-						_input = _input[2:]
-					} else if _input[0] == '"' {
-						// End of double quoted section
-						_input = _input[1:]
-						break
-					} else {
-						// Add the character to our token
-						currentToken += string(_input[0])
-						_input = _input[1:]
-					}
-				}
+// splitWord splits a word at every unquoted occurrence of sep
+func splitWord(word *Word, sep byte) []*Word {
+	words := []*Word{{}}
+	for _, part := range word.Parts {
+		lit, ok := part.(*Literal)
+		if !ok {
+			last := words[len(words)-1]
+			last.Parts = append(last.Parts, part)
+			continue
+		}
+		for i, piece := range strings.Split(lit.Value, string(sep)) {
+			if i > 0 {
+				words = append(words, &Word{})
 			}
-		} else if _input[0] == '\\' && len(_input) > 1 {
-			// Handle backslash escape outside quotes
-			inWord = true
-			// Preserve the literal value of the next character, including space
-			currentToken += string(_input[1])
-			_input = _input[2:]
-		} else if strings.ContainsRune(" \t", rune(_input[0])) {
-			// Whitespace outside quotes means end of current token
-			if inWord {
-				tokens = append(tokens, currentToken)
-				currentToken = ""
-				inWord = false
+			if piece != "" {
+				last := words[len(words)-1]
+				last.Parts = append(last.Parts, &Literal{Value: piece})
 			}
-			_input = _input[1:]
-		} else {
-			// Regular character outside quotes
-			inWord = true
-			currentToken += string(_input[0])
-			_input = _input[1:]
 		}
 	}
+	return words
+}
+
+// parseCase parses case word in [(]pattern[|pattern]...) list ;; ... esac
+func (p *Parser) parseCase() (*CaseClause, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.tok.Kind != WORD {
+		return nil, p.unexpected()
+	}
+	clause := &CaseClause{Word: p.tok.Word}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !p.isReserved("esac") {
+		item, err := p.parseCaseItem()
+		if err != nil {
+			return nil, err
+		}
+		clause.Items = append(clause.Items, item)
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	redirects, err := p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	clause.Redirects = redirects
+	return clause, nil
+}
+
+// parseCaseItem parses the patterns and body of one item of a case command
+func (p *Parser) parseCaseItem() (*CaseItem, error) {
+	item := &CaseItem{Terminator: CASE_BREAK}
+
+	if p.isOperator("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		if p.tok.Kind != WORD {
+			return nil, p.unexpected()
+		}
+		item.Patterns = append(item.Patterns, p.tok.Word)
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !p.isOperator(PIPE) {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if !p.isOperator(")") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	// The body may be empty
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	item.Body = body
+
+	// The terminator can be left out after the last item
+	if p.isCaseTerminator() {
+		item.Terminator = p.tok.Value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	} else if !p.isReserved("esac") {
+		return nil, p.unexpected()
+	}
+	return item, nil
+}
+
+// isFunctionName reports whether the current word is the name of a
+// function definition, i.e. it is followed by ()
+func (p *Parser) isFunctionName() bool {
+	if p.tok.Kind != WORD {
+		return false
+	}
+	if _, ok := p.tok.Word.Lit(); !ok {
+		return false
+	}
+	rest := strings.TrimLeft(p.lexer.input[p.lexer.pos:], " \t")
+	if !strings.HasPrefix(rest, "(") {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeft(rest[1:], " \t"), ")")
+}
+
+// parseFunction parses a function definition, either name() body or,
+// when keyword is set, function name [()] body
+func (p *Parser) parseFunction(keyword bool) (*FunctionDef, error) {
+	if keyword {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	name, ok := "", false
+	if p.tok.Kind == WORD {
+		name, ok = p.tok.Word.Lit()
+	}
+	if !ok || isReservedWord(name) {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !p.isOperator(")") {
+			return nil, p.unexpected()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
 
-	// Add the final token if there is one
-	if currentToken != "" {
-		tokens = append(tokens, currentToken)
+	// The body has to be a compound command
+	if p.tok.Kind != WORD && !p.isOperator("(") {
+		return nil, p.unexpected()
+	}
+	body, err := p.parseCommand()
+	if err != nil {
+		return nil, err
+	}
+	switch body.(type) {
+	case *SimpleCommand, *FunctionDef:
+		return nil, fmt.Errorf("syntax error: `%s': function body must be a compound command", name)
 	}
+	return &FunctionDef{Name: name, Body: body}, nil
+}
+
+// isReservedWord reports whether word is one of the shell's reserved words
+func isReservedWord(word string) bool {
+	switch word {
+	case "!", "{", "}", "if", "then", "elif", "else", "fi", "while", "until",
+		"for", "in", "do", "done", "case", "esac", "function":
+		return true
+	}
+	return false
+}
 
-	tokens, stdoutFile, stderrFile, err := utils.RedirectionImpl(tokens)
+// parseDoGroup parses the do ... done body of a loop
+func (p *Parser) parseDoGroup() (*List, error) {
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.parseCompoundList()
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
+	}
+	if err := p.expect("done"); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// parseCompoundList parses the non-empty list inside a compound command,
+// which may start on a following line
+func (p *Parser) parseCompoundList() (*List, error) {
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	if len(body.Items) == 0 {
+		return nil, p.unexpected()
+	}
+	return body, nil
+}
+
+// parseGroup parses the list between an opening token and its closing token
+func (p *Parser) parseGroup(closing string) (*List, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseCompoundList()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.isOperator(closing) && !p.isReserved(closing) {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// parseSimpleCommand parses words and redirections in any order
+func (p *Parser) parseSimpleCommand() (*SimpleCommand, error) {
+	cmd := &SimpleCommand{}
+
+	for {
+		if p.tok.Kind == WORD && len(cmd.Args) == 0 && IsAssignment(p.tok.Word) {
+			cmd.Assigns = append(cmd.Assigns, p.tok.Word)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.tok.Kind == WORD {
+			cmd.Args = append(cmd.Args, p.tok.Word)
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		redirect, err := p.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		if redirect == nil {
+			break
+		}
+		cmd.Redirects = append(cmd.Redirects, redirect)
 	}
 
-	return tokens, stdoutFile, stderrFile, nil
+	if len(cmd.Assigns) == 0 && len(cmd.Args) == 0 && len(cmd.Redirects) == 0 {
+		return nil, p.unexpected()
+	}
+	return cmd, nil
+}
+
+// IsAssignment reports whether a word is an assignment, NAME=value, whose
+// name is unquoted literal text
+func IsAssignment(word *Word) bool {
+	if len(word.Parts) == 0 {
+		return false
+	}
+	lit, ok := word.Parts[0].(*Literal)
+	if !ok {
+		return false
+	}
+	name := readName(lit.Value)
+	return name != "" && strings.HasPrefix(lit.Value[len(name):], "=")
+}
+
+// parseRedirects parses the redirections following a compound command
+func (p *Parser) parseRedirects() ([]*Redirect, error) {
+	var redirects []*Redirect
+	for {
+		redirect, err := p.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		if redirect == nil {
+			return redirects, nil
+		}
+		redirects = append(redirects, redirect)
+	}
+}
+
+// isRedirectOperator reports whether op is a redirection operator
+func isRedirectOperator(op string) bool {
+	switch op {
+	case "<", "<>", "<&", "<<<", ">", ">>", ">|", ">&", "&>", "&>>", "&>|", "<<", "<<-":
+		return true
+	}
+	return false
+}
+
+// parseRedirect parses a redirection if one starts at the current token,
+// returning nil otherwise
+func (p *Parser) parseRedirect() (*Redirect, error) {
+	redirect := &Redirect{Fd: -1}
+
+	if p.tok.Kind == IO_NUMBER {
+		fd, err := strconv.Atoi(p.tok.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad file descriptor", p.tok.Value)
+		}
+		redirect.Fd = fd
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.Kind != OPERATOR || !isRedirectOperator(p.tok.Value) {
+			return nil, p.unexpected()
+		}
+	} else if p.tok.Kind != OPERATOR || !isRedirectOperator(p.tok.Value) {
+		return nil, nil
+	}
+
+	redirect.Op = p.tok.Value
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.Kind != WORD {
+		return nil, p.unexpected()
+	}
+	redirect.Target = p.tok.Word
+	if redirect.Op == "<<" || redirect.Op == "<<-" {
+		// The body follows the end of the current line
+		p.lexer.heredocs = append(p.lexer.heredocs, redirect)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return redirect, nil
 }
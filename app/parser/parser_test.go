@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// command returns the only command of the first and-or list of input
+func command(t *testing.T, input string) Command {
+	t.Helper()
+	list, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", input, err)
+	}
+	if len(list.Items) == 0 {
+		t.Fatalf("Parse(%q): no commands", input)
+	}
+	commands := list.Items[0].AndOr.Pipelines[0].Commands
+	if len(commands) != 1 {
+		t.Fatalf("Parse(%q): %d commands in the pipeline", input, len(commands))
+	}
+	return commands[0]
+}
+
+func TestWordParts(t *testing.T) {
+	tests := []struct {
+		word string
+		want []WordPart
+	}{
+		{`abc`, []WordPart{&Literal{"abc"}}},
+		{`a\ b`, []WordPart{&Literal{"a"}, &Escaped{" "}, &Literal{"b"}}},
+		{`'a $b'`, []WordPart{&SingleQuoted{"a $b"}}},
+		{`x'y'z`, []WordPart{&Literal{"x"}, &SingleQuoted{"y"}, &Literal{"z"}}},
+		{`"a $b"`, []WordPart{&DoubleQuoted{[]WordPart{&Literal{"a "}, &ParamExp{Name: "b", Short: true}}}}},
+		{`"a\"b\$c\d"`, []WordPart{&DoubleQuoted{[]WordPart{&Literal{`a"b$c\d`}}}}},
+		{`""`, []WordPart{&DoubleQuoted{}}},
+		{`$1x`, []WordPart{&ParamExp{Name: "1", Short: true}, &Literal{"x"}}},
+		{`${#a}`, []WordPart{&ParamExp{Name: "a", Length: true}}},
+		{`${a:-b c}`, []WordPart{&ParamExp{Name: "a", Op: ":-", Arg: &Word{[]WordPart{&Literal{"b c"}}}}}},
+		{`${a[1]}`, []WordPart{&ParamExp{Name: "a", Index: &Word{[]WordPart{&Literal{"1"}}}}}},
+		{`$`, []WordPart{&Literal{"$"}}},
+	}
+	for _, tt := range tests {
+		cmd, ok := command(t, "echo "+tt.word).(*SimpleCommand)
+		if !ok || len(cmd.Args) != 2 {
+			t.Errorf("%s: not parsed as a single argument", tt.word)
+			continue
+		}
+		if got := cmd.Args[1].Parts; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestCmdSubst(t *testing.T) {
+	for _, word := range []string{"$(echo a)", "`echo a`", `"$(echo a)"`} {
+		cmd := command(t, "echo "+word).(*SimpleCommand)
+		part := cmd.Args[1].Parts[0]
+		if quoted, ok := part.(*DoubleQuoted); ok {
+			part = quoted.Parts[0]
+		}
+		subst, ok := part.(*CmdSubst)
+		if !ok {
+			t.Errorf("%s: got %#v, want a command substitution", word, part)
+			continue
+		}
+		if subst.Backquote != (word[0] == '`') {
+			t.Errorf("%s: Backquote is %v", word, subst.Backquote)
+		}
+		inner := subst.Body.Items[0].AndOr.Pipelines[0].Commands[0].(*SimpleCommand)
+		if lit, _ := inner.Args[1].Lit(); lit != "a" {
+			t.Errorf("%s: got body argument %q, want a", word, lit)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	inputs := []string{
+		`echo 'a`,
+		`echo "a`,
+		"echo a |",
+		"true &&",
+		"false ||",
+		"if true; then",
+		"if true; then echo; else",
+		"while true; do",
+		"for i in a b",
+		"case a in",
+		"{ echo",
+		"( echo",
+		"f() {",
+		"echo $(echo",
+		"echo `echo",
+		"echo ${a",
+		"echo $((1 +",
+		`echo a \`,
+		"cat <<EOF\nbody",
+	}
+	for _, input := range inputs {
+		if _, err := Parse(input); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q): got %v, want ErrIncomplete", input, err)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	inputs := []string{
+		"| echo",
+		"echo ;;",
+		"if; then fi",
+		"echo )",
+		"done",
+	}
+	for _, input := range inputs {
+		_, err := Parse(input)
+		if err == nil {
+			t.Errorf("Parse(%q): no error", input)
+		} else if errors.Is(err, ErrIncomplete) {
+			t.Errorf("Parse(%q): got %v, want a syntax error", input, err)
+		}
+	}
+}
+
+func TestHeredoc(t *testing.T) {
+	tests := []struct {
+		input string
+		op    string
+		body  []WordPart
+	}{
+		{"cat <<EOF\na $b\nEOF", "<<", []WordPart{&Literal{"a "}, &ParamExp{Name: "b", Short: true}, &Literal{"\n"}}},
+		{"cat <<'EOF'\na $b\nEOF", "<<", []WordPart{&SingleQuoted{"a $b\n"}}},
+		{"cat <<\"EOF\"\na $b\nEOF", "<<", []WordPart{&SingleQuoted{"a $b\n"}}},
+		{"cat <<-EOF\n\t\ta\n\tEOF", "<<-", []WordPart{&Literal{"a\n"}}},
+		{"cat <<EOF\nEOF", "<<", nil},
+	}
+	for _, tt := range tests {
+		cmd := command(t, tt.input).(*SimpleCommand)
+		if len(cmd.Redirects) != 1 {
+			t.Errorf("%q: got %d redirections, want 1", tt.input, len(cmd.Redirects))
+			continue
+		}
+		r := cmd.Redirects[0]
+		if r.Op != tt.op {
+			t.Errorf("%q: got operator %s, want %s", tt.input, r.Op, tt.op)
+		}
+		var body []WordPart
+		if r.Body != nil {
+			body = r.Body.Parts
+		}
+		if !reflect.DeepEqual(body, tt.body) {
+			t.Errorf("%q: got body %#v, want %#v", tt.input, body, tt.body)
+		}
+	}
+}
+
+func TestHeredocFollowedByCommand(t *testing.T) {
+	list, err := Parse("cat <<A; cat <<B\na\nA\nb\nB\necho done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 3 {
+		t.Fatalf("got %d commands, want 3", len(list.Items))
+	}
+	for i, want := range []string{"a\n", "b\n"} {
+		cmd := list.Items[i].AndOr.Pipelines[0].Commands[0].(*SimpleCommand)
+		if got, _ := cmd.Redirects[0].Body.Lit(); got != want {
+			t.Errorf("body %d: got %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestDoubleParen(t *testing.T) {
+	tests := []struct {
+		input string
+		arith bool
+	}{
+		{"((1 + 2))", true},
+		{"(( x = 1 ))", true},
+		{"( (echo a) )", false},
+		{"((echo a) )", false},
+		{"((echo a); (echo b))", false},
+	}
+	for _, tt := range tests {
+		switch cmd := command(t, tt.input).(type) {
+		case *ArithCommand:
+			if !tt.arith {
+				t.Errorf("%q: parsed as an arithmetic command", tt.input)
+			}
+		case *Subshell:
+			if tt.arith {
+				t.Errorf("%q: parsed as a subshell", tt.input)
+			} else if _, ok := cmd.Body.Items[0].AndOr.Pipelines[0].Commands[0].(*Subshell); !ok {
+				t.Errorf("%q: inner command is not a subshell", tt.input)
+			}
+		default:
+			t.Errorf("%q: got %T", tt.input, cmd)
+		}
+	}
+}
+
+func TestCaseTerminators(t *testing.T) {
+	cmd, ok := command(t, "case x in a) echo a;; b|c) echo b;& d) echo d;;& *) echo e\nesac").(*CaseClause)
+	if !ok {
+		t.Fatal("not parsed as a case command")
+	}
+	want := []struct {
+		patterns   []string
+		terminator string
+	}{
+		{[]string{"a"}, ";;"},
+		{[]string{"b", "c"}, ";&"},
+		{[]string{"d"}, ";;&"},
+		{[]string{"*"}, ";;"},
+	}
+	if len(cmd.Items) != len(want) {
+		t.Fatalf("got %d items, want %d", len(cmd.Items), len(want))
+	}
+	for i, item := range cmd.Items {
+		var patterns []string
+		for _, p := range item.Patterns {
+			lit, _ := p.Lit()
+			patterns = append(patterns, lit)
+		}
+		if !reflect.DeepEqual(patterns, want[i].patterns) {
+			t.Errorf("item %d: got patterns %q, want %q", i, patterns, want[i].patterns)
+		}
+		if item.Terminator != want[i].terminator {
+			t.Errorf("item %d: got terminator %q, want %q", i, item.Terminator, want[i].terminator)
+		}
+	}
+}
@@ -15,8 +15,9 @@ import (
 
 // PromptConfig stores configuration for the shell prompt
 type PromptConfig struct {
-	Prompt     string
-	HistoryMax int
+	Prompt       string
+	Continuation string // shown while a command spans several lines
+	HistoryMax   int
 }
 
 // Prompter manages the terminal input and history
@@ -378,26 +379,52 @@ func getFileCompletions(prefix string) []string {
 	return completions
 }
 
-// ReadLine reads a line from the terminal with history support
-func (p *Prompter) ReadLine() (string, error) {
-	line, err := p.Term.ReadLine()
+// ReadCommand reads a whole command, which can span several lines: as long
+// as incomplete reports the input read so far as unfinished, for example
+// inside quotes or an if command, it reads a further line with the
+// continuation prompt. The command is added to the history as one entry.
+func (p *Prompter) ReadCommand(incomplete func(input string) bool) (string, error) {
+	input, err := p.Term.ReadLine()
 	if err != nil {
 		return "", err
 	}
 
-	// Add non-empty lines to history
-	if line = strings.TrimSpace(line); line != "" {
-		// Add to history if different from last entry
-		if len(p.History) == 0 || p.History[len(p.History)-1] != line {
-			// If history is full, remove oldest entry
-			if len(p.History) >= p.Config.HistoryMax {
-				p.History = p.History[1:]
-			}
-			p.History = append(p.History, line)
+	// Blanks around the line are kept, since a quote can span lines
+	for strings.TrimSpace(input) != "" && incomplete(input) {
+		line, err := p.ReadContinuation()
+		if err != nil {
+			// Let the caller report the unfinished command
+			break
 		}
+		input += "\n" + line
 	}
 
-	return line, nil
+	p.addHistory(strings.TrimSpace(input))
+	return input, nil
+}
+
+// ReadContinuation reads a further line of a command that is not complete
+// yet, such as the body of a here-document, showing the continuation prompt
+func (p *Prompter) ReadContinuation() (string, error) {
+	p.Term.SetPrompt(p.Config.Continuation)
+	defer p.Term.SetPrompt(p.Config.Prompt)
+
+	return p.Term.ReadLine()
+}
+
+// addHistory adds a non-empty entry to the history
+func (p *Prompter) addHistory(entry string) {
+	if entry == "" {
+		return
+	}
+	// Add to history if different from last entry
+	if len(p.History) == 0 || p.History[len(p.History)-1] != entry {
+		// If history is full, remove oldest entry
+		if len(p.History) >= p.Config.HistoryMax {
+			p.History = p.History[1:]
+		}
+		p.History = append(p.History, entry)
+	}
 }
 
 // Close restores the terminal to its original state
@@ -1,26 +1,68 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
-	"os"
 	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/commands"
 )
 
-func ExecImpl(command string, args []string) {
+// ExecImpl runs an external command with the given streams and the exported
+// variables of env attached and returns its exit status.
+func ExecImpl(command string, args []string, streams Streams, env commands.Env) int {
 	if command == "" {
-		fmt.Fprintf(os.Stderr, "%s: command not found\n", command)
-		return
+		fmt.Fprintf(streams.Stderr, "%s: command not found\n", command)
+		return 127
 	}
 
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	err := cmd.Run()
+	// Names without a slash are looked up in the shell's $PATH, which can
+	// differ from the one of the process
+	path := command
+	if !strings.Contains(command, "/") {
+		var err error
+		if path, err = commands.LookPath(command, env); err != nil {
+			fmt.Fprintf(streams.Stderr, "%s: command not found\n", command)
+			return 127
+		}
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Args[0] = command
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
+	cmd.Stdin = streams.Stdin
+	cmd.ExtraFiles = streams.ExtraFiles()
+	cmd.Env = env.Environ()
+	cmd.Dir = env.Getwd()
+	err := cmd.Start()
+	if err == nil {
+		env.ProcessStarted(cmd.Process.Pid)
+		err = cmd.Wait()
+		env.ProcessExited(cmd.Process.Pid)
+	}
 	if err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			return
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitStatus(exitErr)
 		}
-		fmt.Fprintf(os.Stderr, "%s: command not found\n", command)
+		if errors.Is(err, exec.ErrNotFound) {
+			fmt.Fprintf(streams.Stderr, "%s: command not found\n", command)
+			return 127
+		}
+		fmt.Fprintf(streams.Stderr, "%s: %v\n", command, err)
+		return 126
+	}
+	return 0
+}
+
+// exitStatus converts a process exit error into a shell exit status,
+// mapping termination by a signal to 128+signal like bash does
+func exitStatus(exitErr *exec.ExitError) int {
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
 	}
+	return exitErr.ExitCode()
 }
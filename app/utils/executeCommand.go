@@ -1,45 +1,60 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"syscall"
 
 	"github.com/codecrafters-io/shell-starter-go/app/commands"
 )
 
-func ExecuteCommand(tokens []string) (string, error) {
+// ExecuteCommand runs a single command, builtin or external, against the
+// given streams and shell environment and returns its exit status.
+func ExecuteCommand(tokens []string, streams Streams, env commands.Env) int {
+	if len(tokens) == 0 {
+		return 0
+	}
+
 	command := tokens[0]
 	commandArgs := tokens[1:]
 
 	switch command {
-	case commands.EXIT:
-		if len(commandArgs) == 0 {
-			commands.ExitImpl(nil)
-		} else if len(commandArgs) > 1 {
-			fmt.Fprintf(os.Stderr, "%s: too many arguments\n", commands.EXIT)
-			return "", nil
-		}
-		commands.ExitImpl(&commandArgs[0])
 	case commands.ECHO:
-		commands.EchoImpl(commandArgs)
-		return "", nil
+		if err := commands.EchoImpl(commandArgs, streams.Stdout); err != nil {
+			return writeError(command, err, streams)
+		}
 	case commands.TYPE:
-		commands.TypeImpl(commandArgs)
-		return "", nil
+		return commands.TypeImpl(commandArgs, env, streams.Stdout)
 	case commands.PWD:
-		commands.PwdImpl()
-		return "", nil
+		if err := commands.PwdImpl(env, streams.Stdout); err != nil {
+			return writeError(command, err, streams)
+		}
 	case commands.CD:
 		if len(commandArgs) > 1 {
-			fmt.Fprintf(os.Stderr, "%s: too many arguments\n", commands.CD)
-			return "", nil
-		} else {
-			commands.CdImpl(&commandArgs)
+			fmt.Fprintf(streams.Stderr, "%s: too many arguments\n", commands.CD)
+			return 1
 		}
-		return "", nil
+		return commands.CdImpl(&commandArgs, env, streams.Stderr)
 	default:
-		ExecImpl(command, commandArgs)
-		return "", nil
+		return ExecImpl(command, commandArgs, streams, env)
+	}
+	return 0
+}
+
+// writeError reports a builtin that could not write its output and returns
+// its exit status. Writing to a closed descriptor is a bad file descriptor.
+func writeError(command string, err error, streams Streams) int {
+	if errors.Is(err, os.ErrInvalid) {
+		err = syscall.EBADF
+	}
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
 	}
-	return command + ": command not found", nil
+	msg := err.Error()
+	msg = strings.ToUpper(msg[:1]) + msg[1:]
+	fmt.Fprintf(streams.Stderr, "%s: write error: %s\n", command, msg)
+	return 1
 }
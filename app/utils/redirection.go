@@ -2,87 +2,193 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-// RedirectionImpl processes tokens for output and error redirection.
-// It detects redirection operators (>, >>, 2>, 2>>, &>, &>>) and returns:
-// 1. Modified tokens list with redirection operators and targets removed
-// 2. File descriptors for stdout and stderr (nil if not redirected)
-// 3. Any error encountered during processing
-func RedirectionImpl(tokens []string) ([]string, *os.File, *os.File, error) {
-	var stdoutFile, stderrFile *os.File
-	var err error
+// Streams holds the file descriptors a command runs with. A nil file is a
+// closed descriptor.
+type Streams struct {
+	Stdin  *os.File
+	Stdout *os.File
+	Stderr *os.File
+	Extra  map[int]*os.File // descriptors above 2
+}
 
-	if len(tokens) == 0 {
-		return tokens, nil, nil, nil
+// Get returns the file open on descriptor fd, or nil if it is closed
+func (s *Streams) Get(fd int) *os.File {
+	switch fd {
+	case 0:
+		return s.Stdin
+	case 1:
+		return s.Stdout
+	case 2:
+		return s.Stderr
 	}
+	return s.Extra[fd]
+}
 
-	cleanTokens := []string{}
-	i := 0
-	for i < len(tokens) {
-		if tokens[i] == ">" || tokens[i] == "1>" || tokens[i] == ">|" {
-			// Standard output redirection (truncate)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for redirection")
-			}
-			stdoutFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
-			i += 2
-		} else if tokens[i] == ">>" || tokens[i] == "1>>" {
-			// Standard output redirection (append)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for appending redirection")
-			}
-			stdoutFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_APPEND)
-			i += 2
-		} else if tokens[i] == "2>" {
-			// Standard error redirection (truncate)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for stderr redirection")
-			}
-			stderrFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
-			i += 2
-		} else if tokens[i] == "2>>" {
-			// Standard error redirection (append)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for stderr appending redirection")
-			}
-			stderrFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_APPEND)
-			i += 2
-		} else if tokens[i] == "&>" || tokens[i] == "&>|" {
-			// Redirect both stdout and stderr (truncate)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for &> redirection")
-			}
-			stdoutFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_TRUNC)
-			stderrFile = stdoutFile // Redirect stderr to same file
-			i += 2
-		} else if tokens[i] == "&>>" {
-			// Redirect both stdout and stderr (append)
-			if i+1 >= len(tokens) {
-				return nil, nil, nil, errors.New("syntax error: no target for &>> redirection")
-			}
-			stdoutFile, err = createFile(tokens[i+1], os.O_CREATE|os.O_WRONLY|os.O_APPEND)
-			stderrFile = stdoutFile // Redirect stderr to same file
-			i += 2
+// Set opens descriptor fd on file, or closes it if file is nil. The map of
+// descriptors above 2 is copied first so that other copies of the streams
+// are not affected.
+func (s *Streams) Set(fd int, file *os.File) {
+	switch fd {
+	case 0:
+		s.Stdin = file
+	case 1:
+		s.Stdout = file
+	case 2:
+		s.Stderr = file
+	default:
+		extra := make(map[int]*os.File, len(s.Extra)+1)
+		for n, f := range s.Extra {
+			extra[n] = f
+		}
+		if file != nil {
+			extra[fd] = file
 		} else {
-			cleanTokens = append(cleanTokens, tokens[i])
-			i++
+			delete(extra, fd)
 		}
+		s.Extra = extra
+	}
+}
+
+// ExtraFiles returns the descriptors above 2 laid out for exec.Cmd, where
+// entry i becomes descriptor 3+i and nil entries stay closed
+func (s *Streams) ExtraFiles() []*os.File {
+	var files []*os.File
+	for fd, file := range s.Extra {
+		for len(files) <= fd-3 {
+			files = append(files, nil)
+		}
+		files[fd-3] = file
+	}
+	return files
+}
+
+// RedirectionImpl applies a single redirection to streams, resolving a
+// relative target against dir. Fd is the descriptor written before the
+// operator, or -1 for the operator's default:
+//
+//	< file    open file for reading on fd 0
+//	<> file   open file for reading and writing on fd 0
+//	> file    create or truncate file on fd 1 (also >|)
+//	>> file   append to file on fd 1
+//	&> file   send both fd 1 and fd 2 to file (also &>| and &>>)
+//	<& n      make fd 0 a copy of fd n, or close it if n is -
+//	>& n      make fd 1 a copy of fd n, or close it if n is -
+//	<<< word  feed word and a newline to fd 0
+//
+// It returns the file it opened, if any, so the caller can close it once the
+// command has finished.
+func RedirectionImpl(streams *Streams, dir string, fd int, op string, target string) (*os.File, error) {
+	if fd == -1 {
+		fd = 1
+		if strings.HasPrefix(op, "<") {
+			fd = 0
+		}
+	}
+
+	switch op {
+	case "<<<":
+		return HeredocImpl(streams, fd, target+"\n")
+	case ">&", "<&":
+		if target == "-" {
+			streams.Set(fd, nil)
+			return nil, nil
+		}
+		source, err := strconv.Atoi(target)
 		if err != nil {
-			return nil, nil, nil, err
+			if op == ">&" && fd == 1 {
+				// >&file is another way to write &>file
+				return RedirectionImpl(streams, dir, -1, "&>", target)
+			}
+			return nil, fmt.Errorf("%s: ambiguous redirect", target)
 		}
+		file := streams.Get(source)
+		if file == nil {
+			return nil, fmt.Errorf("%d: Bad file descriptor", source)
+		}
+		streams.Set(fd, file)
+		return nil, nil
+	}
+
+	var flag int
+	switch op {
+	case "<":
+		flag = os.O_RDONLY
+	case "<>":
+		flag = os.O_CREATE | os.O_RDWR
+	case ">>", "&>>":
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	default:
+		flag = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
 	}
 
-	return cleanTokens, stdoutFile, stderrFile, nil
+	path := target
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	file, err := openFile(path, flag, target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "&>", "&>>", "&>|":
+		// Redirect both stdout and stderr to the same file
+		streams.Stdout = file
+		streams.Stderr = file
+	default:
+		streams.Set(fd, file)
+	}
+	return file, nil
 }
 
-// createFile ensures the target file exists and opens it with the given flag
-func createFile(filename string, flag int) (*os.File, error) {
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
+// HeredocImpl feeds the body of a here-document or here-string to the
+// standard input, or to descriptor fd when one was given. The body is written to an unlinked
+// temporary file so that commands which never read it do not block. The file
+// is returned so the caller can close it once the command has finished.
+func HeredocImpl(streams *Streams, fd int, body string) (*os.File, error) {
+	if fd == -1 {
+		fd = 0
+	}
+
+	file, err := os.CreateTemp("", "heredoc")
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(file.Name())
+
+	if _, err := file.WriteString(body); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	streams.Set(fd, file)
+	return file, nil
+}
+
+// openFile opens a redirection target with the given flag. Missing parent
+// directories are an error, reported like the shell reports them using the
+// name the target was written as.
+func openFile(path string, flag int, name string) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			msg := pathErr.Err.Error()
+			return nil, fmt.Errorf("%s: %s", name, strings.ToUpper(msg[:1])+msg[1:])
+		}
 		return nil, err
 	}
-	return os.OpenFile(filename, flag, 0o644)
+	return file, nil
 }